@@ -2,12 +2,32 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/joho/godotenv"
 	"github.com/wallacehenriquesilva/slack-assets-bot/internal/adapter"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/core/model"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/core/port/in"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/core/port/out"
 	coreservice "github.com/wallacehenriquesilva/slack-assets-bot/internal/core/service"
+	extmodel "github.com/wallacehenriquesilva/slack-assets-bot/internal/model"
 	extservice "github.com/wallacehenriquesilva/slack-assets-bot/internal/service"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/util/fileutil"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	slackModeSocket = "socket"
+	slackModeHTTP   = "http"
 )
 
 func main() {
@@ -25,30 +45,456 @@ func main() {
 	repository := os.Getenv("GITHUB_REPOSITORY")
 	authorName := os.Getenv("GITHUB_AUTHOR_NAME")
 	authorEmail := os.Getenv("GITHUB_AUTHOR_EMAIL")
+	slackMode := getEnv("SLACK_MODE", slackModeSocket)
+	slackSigningSecret := os.Getenv("SLACK_SIGNING_SECRET")
+	slackHTTPAddr := getEnv("SLACK_HTTP_ADDR", ":3000")
+	slackMaxSkew := getDurationEnv("SLACK_MAX_SKEW", 5*time.Minute)
+	discordToken := os.Getenv("DISCORD_TOKEN")
+	discordGatewayURL := getEnv("DISCORD_GATEWAY_URL", extservice.DefaultDiscordGatewayURL)
+	discordAPIURL := getEnv("DISCORD_API_URL", extservice.DefaultDiscordAPIURL)
+	mattermostURL := os.Getenv("MATTERMOST_URL")
+	mattermostToken := os.Getenv("MATTERMOST_TOKEN")
+	mattermostActionsURL := os.Getenv("MATTERMOST_ACTIONS_URL")
+	mattermostActionsAddr := getEnv("MATTERMOST_ACTIONS_ADDR", ":3001")
+	teamsAppID := os.Getenv("TEAMS_APP_ID")
+	teamsAppPassword := os.Getenv("TEAMS_APP_PASSWORD")
+	teamsTenantID := os.Getenv("TEAMS_TENANT_ID")
+	teamsAddr := getEnv("TEAMS_ADDR", ":3978")
+	webhookSecret := os.Getenv("GITHUB_WEBHOOK_SECRET")
+	webhookAddr := os.Getenv("GITHUB_WEBHOOK_ADDR")
+	storePath := getEnv("STORE_PATH", "data/store.db")
+	routesFile := os.Getenv("ROUTES_FILE")
+	previewLimit := getIntEnv("MESSAGE_PREVIEW_LIMIT", 0)
+	formCategories := getListEnv("FORM_CATEGORIES")
+	jobWorkers := getIntEnv("JOB_WORKERS", 2)
+	jobQueueSize := getIntEnv("JOB_QUEUE_SIZE", 20)
+	jobTimeout := getDurationEnv("JOB_TIMEOUT", 15*time.Minute)
+	maxFileSize := int64(getIntEnv("MAX_FILE_SIZE_MB", 100)) << 20
+	shutdownGracePeriod := getDurationEnv("SHUTDOWN_GRACE_PERIOD", 25*time.Second)
+	dedupTTL := getDurationEnv("DEDUP_TTL", time.Hour)
+	jobRetention := getDurationEnv("JOB_RETENTION", 30*24*time.Hour)
+	retryPolicy := coreservice.RetryPolicy{
+		Attempts:  getIntEnv("RETRY_ATTEMPTS", 3),
+		BaseDelay: getDurationEnv("RETRY_BASE_DELAY", time.Second),
+		MaxDelay:  getDurationEnv("RETRY_MAX_DELAY", time.Minute),
+	}
+	timeouts := model.StageTimeouts{
+		Download:    getDurationEnv("DOWNLOAD_TIMEOUT", 5*time.Minute),
+		Commit:      getDurationEnv("COMMIT_TIMEOUT", 2*time.Minute),
+		PullRequest: getDurationEnv("PULL_REQUEST_TIMEOUT", 30*time.Second),
+		Message:     getDurationEnv("MESSAGE_TIMEOUT", 10*time.Second),
+	}
+	approval := coreservice.NewApprovalConfig(
+		os.Getenv("APPROVAL_ENABLED") == "true",
+		getListEnv("APPROVERS"),
+		getDurationEnv("APPROVAL_TIMEOUT", 30*time.Minute),
+	)
+	branchTemplate := getEnv("BRANCH_TEMPLATE", "asset-{{ uuid }}")
+	janitorEnabled := os.Getenv("BRANCH_JANITOR_ENABLED") == "true"
+	janitorPrefix := getEnv("BRANCH_JANITOR_PREFIX", strings.SplitN(branchTemplate, "{{", 2)[0])
+	janitorMaxAge := getDurationEnv("BRANCH_JANITOR_MAX_AGE", 7*24*time.Hour)
+	janitorInterval := getDurationEnv("BRANCH_JANITOR_INTERVAL", 24*time.Hour)
+	janitorDryRun := os.Getenv("BRANCH_JANITOR_DRY_RUN") != "false"
+	commitMessageTemplate := getEnv("COMMIT_MESSAGE_TEMPLATE", "bot: {{ with .Title }}{{ . }}{{ else }}add new assets{{ end }}")
+	prTitleTemplate := getEnv("PR_TITLE_TEMPLATE", ":robot: {{ with .Title }}{{ . }}{{ else }}New assets{{ end }}")
+	prDescriptionTemplate := getEnv("PR_DESCRIPTION_TEMPLATE", `
+## Description
+{{ with .Description }}{{ . }}{{ else }}- Adds the new assests using the slack bot.{{ end }}
+`)
+
+	// The signal stops the intake, the jobs get their own context so they can
+	// finish during the grace period.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// The events being processed, drained on shutdown once the connections
+	// receiving them, counted in running, are closed.
+	var running, handling sync.WaitGroup
 
 	slackService := extservice.NewSlackClient(token, appToken, channelID)
-	githubService := extservice.NewGithubClient(githubToken, owner, repository, authorName, authorEmail)
+
+	store, err := openStore(storePath)
+	if err != nil {
+		log.Fatalf("error to open the store: %v\n", err)
+	}
+
+	tempDir, err := fileutil.NewTempDir("slack-assets-bot-")
+	if err != nil {
+		log.Fatalf("error to create the temp folder: %v\n", err)
+	}
 
 	slackAdapter := adapter.NewSlackAdapter(slackService)
-	githubAdapter := adapter.NewGithubAdapter(githubService)
+	storeAdapter := adapter.NewStoreAdapter(store, jobRetention)
 
-	baseBranch := "main"
-	commitMessage := "bot: add new assets"
-	prTitle := ":robot: New assets"
-	prDescription := `
-## Description
-- Adds the new assests using the slack bot.
-`
+	routes := []extmodel.RouteConfig{defaultRoute(channelID, owner, repository)}
+	if routesFile != "" {
+		if routes, err = extservice.LoadRoutes(routesFile); err != nil {
+			log.Fatalf("error to load the routes: %v\n", err)
+		}
+	}
+
+	router, err := adapter.NewRouter(routes, func(owner, repository string) out.VersionControlSystem {
+		return adapter.NewGithubAdapter(extservice.NewGithubClient(githubToken, owner, repository, authorName, authorEmail))
+	})
+	if err != nil {
+		log.Fatalf("error to load the routes: %v\n", err)
+	}
+
+	baseBranches := make([]string, 0, len(router.Routes()))
+	for _, route := range router.Routes() {
+		baseBranches = append(baseBranches, route.BaseBranch)
+	}
+
+	templates, err := coreservice.NewTemplates(branchTemplate, commitMessageTemplate, prTitleTemplate, prDescriptionTemplate,
+		baseBranches)
+	if err != nil {
+		log.Fatalf("error to load the templates: %v\n", err)
+	}
+
+	botUserID, err := slackService.GetBotUserID(ctx)
+	if err != nil {
+		log.Fatalf("error to get the bot user: %v\n", err)
+	}
+
+	eventFilter := adapter.NewEventFilter(router.Channels(), botUserID)
+
+	// Every chat replies through its own message system, picked by the
+	// platform of the conversation, so the jobs of any chat can be approved,
+	// listed and retried from the others.
+	messageSystems := map[model.Platform]in.MessageSystem{model.PlatformSlack: slackAdapter}
+
+	var discordService extservice.DiscordClient
+	if discordToken != "" {
+		discordService = extservice.NewDiscordClient(discordToken, discordGatewayURL, discordAPIURL, &handling)
+		messageSystems[model.PlatformDiscord] = adapter.NewDiscordAdapter(discordService)
+	}
+
+	var mattermostService extservice.MattermostClient
+	if mattermostURL != "" {
+		if mattermostService, err = extservice.NewMattermostClient(mattermostToken, mattermostURL, mattermostActionsURL,
+			&handling); err != nil {
+			log.Fatalf("error to create the Mattermost client: %v\n", err)
+		}
+		messageSystems[model.PlatformMattermost] = adapter.NewMattermostAdapter(mattermostService)
+	}
+
+	var teamsService extservice.TeamsClient
+	if teamsAppID != "" {
+		teamsService = extservice.NewTeamsClient(teamsAppID, teamsAppPassword, teamsTenantID, &handling)
+		messageSystems[model.PlatformTeams] = adapter.NewTeamsAdapter(teamsService)
+	}
+
+	messageSystem := adapter.NewMessageSystemRouter(messageSystems)
+
+	queue := coreservice.NewJobQueue(jobWorkers, jobQueueSize, jobTimeout)
+	assetService := coreservice.NewAssetService(messageSystem, storeAdapter, storeAdapter, templates, previewLimit, approval, queue,
+		storeAdapter, dedupTTL, retryPolicy, timeouts, maxFileSize)
+	intakeAdapter := adapter.NewIntakeAdapter(assetService, router)
+	assetAdapter := adapter.NewAssetAdapter(intakeAdapter, slackService, eventFilter)
+	formAdapter := adapter.NewFormAdapter(intakeAdapter, slackService, router, formCategories)
+	interactionAdapter := adapter.NewInteractionAdapter(assetService, formAdapter)
+	commandAdapter := adapter.NewCommandAdapter(assetService, router, formAdapter)
+
+	queue.Start(context.Background())
+	resumeJobs(ctx, assetService, router)
+
+	if janitorEnabled {
+		startJanitor(ctx, storeAdapter, router, janitorPrefix, janitorMaxAge, janitorInterval, janitorDryRun)
+	}
+
+	// The webhook and the endpoints of the chats share a server when they
+	// listen on the same address.
+	muxes := map[string]*http.ServeMux{}
+
+	if discordService != nil {
+		discordAssetAdapter := adapter.NewDiscordAssetAdapter(intakeAdapter, assetService, discordService)
+
+		running.Add(1)
+		go func() {
+			defer running.Done()
+			err := discordService.StartGateway(ctx, extservice.DiscordHandlers{
+				Messages:     discordAssetAdapter.ProcessMessage,
+				Interactions: discordAssetAdapter.ProcessInteraction,
+			})
+			if err != nil {
+				log.Fatalf("error to connect to the Discord gateway: %v\n", err)
+			}
+		}()
+	}
+
+	if mattermostService != nil {
+		mattermostAssetAdapter := adapter.NewMattermostAssetAdapter(intakeAdapter, assetService, mattermostService)
+
+		serveMux(muxes, mattermostActionsAddr).Handle(extservice.MattermostActionsPath,
+			mattermostService.ActionsHandler(ctx, mattermostAssetAdapter.ProcessDecision))
+
+		running.Add(1)
+		go func() {
+			defer running.Done()
+			if err := mattermostService.StartWebsocket(ctx, mattermostAssetAdapter.ProcessPost); err != nil {
+				log.Fatalf("error to connect to the Mattermost websocket: %v\n", err)
+			}
+		}()
+	}
+
+	if teamsService != nil {
+		teamsAssetAdapter := adapter.NewTeamsAssetAdapter(intakeAdapter, assetService)
+
+		serveMux(muxes, teamsAddr).Handle(extservice.TeamsMessagesPath, teamsService.Handler(ctx, teamsAssetAdapter.ProcessActivity))
+	}
+
+	handlers := extservice.SlackHandlers{
+		Events:       assetAdapter.Process,
+		Interactions: interactionAdapter.Process,
+		Commands:     commandAdapter.Process,
+		Submissions:  formAdapter.Submit,
+	}
+
+	if webhookAddr != "" {
+		if webhookSecret == "" {
+			log.Fatalln("the GITHUB_WEBHOOK_SECRET is required to start the webhook server")
+		}
+
+		notificationService := coreservice.NewNotificationService(messageSystem, storeAdapter)
+		webhookAdapter := adapter.NewGithubWebhookAdapter(notificationService)
+		webhook := extservice.NewGithubWebhook(webhookSecret)
+
+		serveMux(muxes, webhookAddr).Handle("/github/webhook", webhook.Handler(webhookAdapter.Process))
+	}
+
+	switch slackMode {
+	case slackModeSocket:
+	case slackModeHTTP:
+		if slackSigningSecret == "" {
+			log.Fatalln("the SLACK_SIGNING_SECRET is required to receive the Slack events over HTTP")
+		}
+
+		slackHTTP := extservice.NewSlackHTTP(slackSigningSecret, slackMaxSkew, &handling)
+		serveMux(muxes, slackHTTPAddr).Handle("/slack/", slackHTTP.Handler(ctx, handlers))
+	default:
+		log.Fatalf("invalid SLACK_MODE %q, use %s or %s\n", slackMode, slackModeSocket, slackModeHTTP)
+	}
+
+	servers := make([]*http.Server, 0, len(muxes))
+	serverErrors := make(chan error, len(muxes))
+	for addr, mux := range muxes {
+		server := &http.Server{Addr: addr, Handler: mux}
+		servers = append(servers, server)
+
+		go func(server *http.Server) {
+			if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				serverErrors <- fmt.Errorf("%s: %w", server.Addr, err)
+			}
+		}(server)
+	}
 
-	assetService := coreservice.NewAssetService(slackAdapter, githubAdapter, baseBranch, commitMessage, prTitle, prDescription)
-	assetAdapter := adapter.NewAssetAdapter(assetService)
+	go func() {
+		log.Fatalf("error to start the server: %v\n", <-serverErrors)
+	}()
 
-	ctx, cancel := context.WithCancel(context.Background())
+	if slackMode == slackModeSocket {
+		running.Add(1)
+		go func() {
+			defer running.Done()
+			if err := slackService.StartSocket(ctx, handlers, &handling); err != nil {
+				log.Fatalln("error to start the socket")
+			}
+		}()
+	}
+
+	<-ctx.Done()
+	// A second signal stops the bot right away.
+	stop()
+	shutdown(servers, &running, &handling, assetService, store, tempDir, shutdownGracePeriod)
+}
+
+// shutdown stops taking new uploads and gives the running ones the grace
+// period to finish. The servers and the connections of the chats are closed
+// and the events already received are processed first, within the same
+// period, so none of them writes to the store after it is closed. The
+// uploads still running or waiting after it are interrupted, they are
+// resumed on the next start.
+func shutdown(servers []*http.Server, running, handling *sync.WaitGroup, assetService coreservice.AssetSetvice,
+	store extservice.KeyValueStore, tempDir string, grace time.Duration) {
+	log.Printf("shutting down, waiting %s for the running uploads\n", grace)
+
+	ctx, cancel := context.WithTimeout(context.Background(), grace)
 	defer cancel()
 
-	err = slackService.StartSocket(ctx, assetAdapter.Process)
+	for _, server := range servers {
+		if err := server.Shutdown(ctx); err != nil {
+			log.Printf("error to stop the server %s: %v\n", server.Addr, err)
+		}
+	}
+
+	deadline, _ := ctx.Deadline()
+	if !waitFor(running, time.Until(deadline)) {
+		log.Printf("the connections of the chats were still open after %s\n", grace)
+	}
+	if !waitFor(handling, time.Until(deadline)) {
+		log.Printf("the events were still being processed after %s\n", grace)
+	}
+	assetService.Stop(time.Until(deadline))
+
+	if err := store.Close(); err != nil {
+		log.Printf("error to close the store: %v\n", err)
+	}
+	if err := os.RemoveAll(tempDir); err != nil {
+		log.Printf("error to remove the temp folder: %v\n", err)
+	}
+
+	log.Println("the bot stopped")
+}
+
+// openStore keeps the JSON store for the paths ending in .json, the other
+// paths are BoltDB files. A new BoltDB file gets the data of the JSON store
+// next to it, as data/store.json for data/store.db, so an install that had the
+// previous default keeps its data.
+func openStore(path string) (extservice.KeyValueStore, error) {
+	if filepath.Ext(path) == ".json" {
+		return extservice.NewJSONStore(path)
+	}
+
+	_, err := os.Stat(path)
+	created := os.IsNotExist(err)
+
+	store, err := extservice.NewBoltStore(path)
+	if err != nil || !created {
+		return store, err
+	}
+
+	jsonPath := strings.TrimSuffix(path, filepath.Ext(path)) + ".json"
+	if _, err = os.Stat(jsonPath); err != nil {
+		return store, nil
+	}
+
+	count, err := store.Import(jsonPath)
 	if err != nil {
-		log.Fatalln("error to start the socket")
+		_ = store.Close()
+		_ = os.Remove(path)
+		return nil, fmt.Errorf("error to import the JSON store %s: %w", jsonPath, err)
 	}
+	log.Printf("imported %d values of the JSON store %s into %s, the JSON file is no longer used\n", count, jsonPath, path)
+
+	return store, nil
+}
+
+// resumeJobs queues again the jobs that were running when the bot stopped.
+// A job whose routes were removed fails and tells the uploader.
+func resumeJobs(ctx context.Context, assetService coreservice.AssetSetvice, router *adapter.Router) {
+	jobs, err := assetService.UnfinishedJobs()
+	if err != nil {
+		log.Printf("error to list the unfinished jobs: %v\n", err)
+		return
+	}
+
+	for _, job := range jobs {
+		targets, err := router.Targets(job.Routes)
+		if err != nil {
+			log.Printf("error to find the routes of the job %s: %v\n", job.ID, err)
+		}
+
+		log.Printf("resuming the job %s from %s\n", job.ID, job.State)
+		if err = assetService.Resume(ctx, job, targets); err != nil {
+			log.Printf("error to resume the job %s: %v\n", job.ID, err)
+		}
+	}
+}
 
+// startJanitor sweeps the stale branches of every routed repository. A
+// janitor without a prefix would match every branch, so it isn't started.
+func startJanitor(ctx context.Context, jobStore out.JobStore, router *adapter.Router, prefix string,
+	maxAge, interval time.Duration, dryRun bool) {
+	if prefix == "" {
+		log.Println("the branch janitor needs a BRANCH_JANITOR_PREFIX, it was not started")
+		return
+	}
+
+	coreservice.NewBranchJanitor(jobStore, router.AllTargets(), prefix, maxAge, interval, dryRun).Start(ctx)
+}
+
+// waitFor waits for the group for timeout at most, telling whether it was
+// done.
+func waitFor(group *sync.WaitGroup, timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		group.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func serveMux(muxes map[string]*http.ServeMux, addr string) *http.ServeMux {
+	if muxes[addr] == nil {
+		muxes[addr] = http.NewServeMux()
+	}
+	return muxes[addr]
+}
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+func getIntEnv(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("invalid number in %s: %v\n", key, err)
+	}
+	return number
+}
+
+func getDurationEnv(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("invalid duration in %s: %v\n", key, err)
+	}
+	return duration
+}
+
+// getListEnv splits a comma separated variable, ignoring the empty items.
+func getListEnv(key string) []string {
+	var items []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// defaultRoute builds the single route used when no ROUTES_FILE is given.
+func defaultRoute(channelID, owner, repository string) extmodel.RouteConfig {
+	return extmodel.RouteConfig{
+		Name:       repository,
+		Channel:    channelID,
+		Owner:      owner,
+		Repository: repository,
+		BaseBranch: getEnv("GITHUB_BASE_BRANCH", "main"),
+		AutoMerge: extmodel.AutoMergeSettings{
+			Mode:              os.Getenv("AUTO_MERGE_MODE"),
+			Method:            os.Getenv("AUTO_MERGE_METHOD"),
+			PollInterval:      os.Getenv("AUTO_MERGE_POLL_INTERVAL"),
+			Timeout:           os.Getenv("AUTO_MERGE_TIMEOUT"),
+			ChecksGracePeriod: os.Getenv("AUTO_MERGE_CHECKS_GRACE_PERIOD"),
+		},
+	}
 }
@@ -1,30 +1,42 @@
 package adapter
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/slack-go/slack/slackevents"
 	coremodel "github.com/wallacehenriquesilva/slack-assets-bot/internal/core/model"
-	coreservice "github.com/wallacehenriquesilva/slack-assets-bot/internal/core/service"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/core/port/in"
 	extmodel "github.com/wallacehenriquesilva/slack-assets-bot/internal/model"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/service"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// AssetAdapter turns the files shared in the Slack channels into upload
+// requests.
 type AssetAdapter struct {
-	assetService coreservice.AssetSetvice
+	intake       in.AssetIntake
+	slackService service.SlackClient
+	eventFilter  *EventFilter
 }
 
-var (
-	MaxNumberofFilesError = fmt.Errorf("invalid number of files. Only one file is allowed")
-	MinNumberofFilesError = fmt.Errorf("invalid number of files. One file is required")
-)
+var MaxNumberofFilesError = fmt.Errorf("invalid number of files. Only one file is allowed")
+
+var slackLinkRegex = regexp.MustCompile(`<[^<>]+>`)
 
-func NewAssetAdapter(assetService coreservice.AssetSetvice) *AssetAdapter {
+func NewAssetAdapter(intake in.AssetIntake, slackService service.SlackClient, eventFilter *EventFilter) *AssetAdapter {
 	return &AssetAdapter{
-		assetService: assetService,
+		intake:       intake,
+		slackService: slackService,
+		eventFilter:  eventFilter,
 	}
 }
 
-func (assetAdapter *AssetAdapter) Process(event slackevents.EventsAPIEvent) error {
+func (assetAdapter *AssetAdapter) Process(ctx context.Context, event slackevents.EventsAPIEvent) error {
 	bytes, err := json.Marshal(event.Data)
 	if err != nil {
 		return err
@@ -37,19 +49,86 @@ func (assetAdapter *AssetAdapter) Process(event slackevents.EventsAPIEvent) erro
 		return err
 	}
 
-	if len(slackEvent.Event.Files) > 1 {
-		_ = assetAdapter.assetService.SendErrorMessage(MaxNumberofFilesError)
-		return MaxNumberofFilesError
-	} else if len(slackEvent.Event.Files) == 0 {
-		_ = assetAdapter.assetService.SendErrorMessage(MinNumberofFilesError)
-		return MinNumberofFilesError
+	if !assetAdapter.eventFilter.Accept(slackEvent.Event) {
+		return nil
 	}
 
-	file := slackEvent.Event.Files[0]
+	files := make([]coremodel.UploadFile, 0, len(slackEvent.Event.Files))
+	for _, file := range slackEvent.Event.Files {
+		files = append(files, coremodel.UploadFile{
+			ID:        file.ID,
+			Url:       file.Url,
+			Extension: file.FileType,
+			Name:      file.Name,
+			Size:      int64(file.Size),
+		})
+	}
+
+	return assetAdapter.intake.Submit(ctx, coremodel.AssetUploadRequest{
+		DeliveryID: slackEvent.EventID,
+		Requester: coremodel.Requester{
+			ID:   slackEvent.Event.User,
+			Name: userName(ctx, assetAdapter.slackService, slackEvent.Event.User),
+		},
+		Conversation: conversationOf(slackEvent.Event),
+		Files:        files,
+		Text:         cleanText(slackEvent.Event.Text),
+		Timestamp:    parseTimestamp(slackEvent.Event.TimeStamp),
+	})
+}
 
-	coreFile := coremodel.AssetFile{
-		Url:       file.Url,
-		Extension: file.FileType,
+// conversationOf returns the thread of the upload, which is the thread the
+// upload was posted in or a new thread under the upload message.
+func conversationOf(event extmodel.Event) coremodel.Conversation {
+	thread := event.ThreadTS
+	if thread == "" {
+		thread = event.TimeStamp
+	}
+
+	return coremodel.Conversation{
+		Platform:  coremodel.PlatformSlack,
+		Channel:   event.Channel,
+		Thread:    thread,
+		MessageID: event.TimeStamp,
+		User:      event.User,
+	}
+}
+
+// userName resolves the Slack user name, falling back to the user ID when the
+// bot can't read the user profile.
+func userName(ctx context.Context, slackService service.SlackClient, userID string) string {
+	name, err := slackService.GetUserName(ctx, userID)
+	if err != nil {
+		log.Printf("error to get the user name of %s: %v\n", userID, err)
+		return userID
+	}
+	return name
+}
+
+// cleanText removes the Slack markup from a message text: links and channel
+// mentions are replaced by their label (or target when there is none) and the
+// escaped characters are restored. The user mentions are kept, the core tells
+// them apart from the GitHub users of the directives.
+func cleanText(text string) string {
+	text = slackLinkRegex.ReplaceAllStringFunc(text, func(link string) string {
+		if strings.HasPrefix(link, "<@") {
+			return link
+		}
+		content := strings.TrimPrefix(link[1:len(link)-1], "mailto:")
+		if index := strings.Index(content, "|"); index >= 0 {
+			return content[index+1:]
+		}
+		return content
+	})
+	return strings.NewReplacer("&lt;", "<", "&gt;", ">", "&amp;", "&").Replace(text)
+}
+
+// parseTimestamp converts a Slack "seconds.micros" timestamp into a time,
+// falling back to now when it is missing or malformed.
+func parseTimestamp(ts string) time.Time {
+	seconds, err := strconv.ParseFloat(ts, 64)
+	if err != nil {
+		return time.Now()
 	}
-	return assetAdapter.assetService.Process(coreFile)
+	return time.Unix(0, int64(seconds*float64(time.Second)))
 }
@@ -0,0 +1,220 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"github.com/slack-go/slack"
+	coremodel "github.com/wallacehenriquesilva/slack-assets-bot/internal/core/model"
+	coreservice "github.com/wallacehenriquesilva/slack-assets-bot/internal/core/service"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultHistorySize = 10
+	maxHistorySize     = 50
+)
+
+const commandHelp = "*Usage:* `/assets <command>`\n" +
+	"`status <upload>` shows the stages of an upload\n" +
+	"`history [n]` lists the last uploads, 10 by default\n" +
+	"`targets` lists the configured routes\n" +
+	"`retry <upload>` processes a failed upload again in the routes without a pull request\n" +
+	"`cancel <upload>` stops an upload in progress\n" +
+	"`upload` opens the upload form\n" +
+	"`help` shows this message"
+
+// CommandAdapter answers the /assets slash command.
+type CommandAdapter struct {
+	assetService coreservice.AssetSetvice
+	router       *Router
+	formAdapter  *FormAdapter
+}
+
+func NewCommandAdapter(assetService coreservice.AssetSetvice, router *Router, formAdapter *FormAdapter) *CommandAdapter {
+	return &CommandAdapter{
+		assetService: assetService,
+		router:       router,
+		formAdapter:  formAdapter,
+	}
+}
+
+func (commandAdapter *CommandAdapter) Process(ctx context.Context, command slack.SlashCommand) (string, error) {
+	args := strings.Fields(command.Text)
+	if len(args) == 0 {
+		return commandHelp, nil
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "status":
+		if len(args) != 2 {
+			return "*Usage:* `/assets status <upload>`", nil
+		}
+		return commandAdapter.status(command.ChannelID, args[1])
+	case "history":
+		size := defaultHistorySize
+		if len(args) > 1 {
+			number, err := strconv.Atoi(args[1])
+			if err != nil || number <= 0 {
+				return "*Usage:* `/assets history [n]`", nil
+			}
+			size = number
+		}
+		if size > maxHistorySize {
+			size = maxHistorySize
+		}
+		return commandAdapter.history(command.ChannelID, size)
+	case "targets":
+		return commandAdapter.targets(), nil
+	case "retry":
+		if len(args) != 2 {
+			return "*Usage:* `/assets retry <upload>`", nil
+		}
+		return commandAdapter.retry(ctx, commandConversation(command), args[1])
+	case "cancel":
+		if len(args) != 2 {
+			return "*Usage:* `/assets cancel <upload>`", nil
+		}
+		return commandAdapter.cancel(ctx, commandConversation(command), args[1])
+	case "upload":
+		return "", commandAdapter.formAdapter.Open(ctx, command.TriggerID)
+	case "help":
+		return commandHelp, nil
+	}
+
+	return fmt.Sprintf("Unknown command `%s`.\n%s", args[0], commandHelp), nil
+}
+
+func (commandAdapter *CommandAdapter) status(channel, id string) (string, error) {
+	job, err := commandAdapter.findJob(channel, id)
+	if err != nil {
+		return "", err
+	}
+
+	lines := []string{
+		fmt.Sprintf("*%s* uploaded by %s on %s to %s", job.AssetFile.Name, job.AssetFile.Uploader,
+			job.CreatedAt.Format("2006-01-02 15:04"), strings.Join(job.Routes, ", ")),
+		statusText(job.Status),
+	}
+	for _, pullRequest := range job.PullRequests {
+		lines = append(lines, pullRequest.Url)
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// history lists the last uploads of the channel the command was run in.
+func (commandAdapter *CommandAdapter) history(channel string, size int) (string, error) {
+	jobs, err := commandAdapter.assetService.ListJobs(0)
+	if err != nil {
+		return "", err
+	}
+
+	lines := make([]string, 0, size)
+	for _, job := range jobs {
+		if job.AssetFile.Conversation.Channel != channel {
+			continue
+		}
+		lines = append(lines, historyLine(job))
+		if len(lines) == size {
+			break
+		}
+	}
+	if len(lines) == 0 {
+		return "No uploads yet.", nil
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+func historyLine(job coremodel.Job) string {
+	line := fmt.Sprintf("`%s` %s *%s* by %s: %s", job.ID, job.CreatedAt.Format("2006-01-02 15:04"),
+		job.AssetFile.Name, job.AssetFile.Uploader, stageNames[coreservice.JobStage(job)])
+
+	urls := make([]string, 0, len(job.PullRequests))
+	for _, pullRequest := range job.PullRequests {
+		urls = append(urls, pullRequest.Url)
+	}
+	if len(urls) > 0 {
+		line += " " + strings.Join(urls, " ")
+	}
+
+	return line
+}
+
+func (commandAdapter *CommandAdapter) targets() string {
+	routes := commandAdapter.router.Routes()
+	if len(routes) == 0 {
+		return "No routes are configured."
+	}
+
+	lines := make([]string, 0, len(routes))
+	for _, route := range routes {
+		line := fmt.Sprintf("*%s*: <#%s> to %s/%s on %s", route.Name, route.Channel, route.Owner, route.Repository,
+			route.BaseBranch)
+		if route.Keyword != "" {
+			line += fmt.Sprintf(" with the keyword `%s`", route.Keyword)
+		}
+		if route.TargetPath != "" {
+			line += fmt.Sprintf(" in `%s`", route.TargetPath)
+		}
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// retry sends the upload again to the routes it went to, as they are
+// configured now.
+func (commandAdapter *CommandAdapter) retry(ctx context.Context, requester coremodel.Conversation, id string) (string, error) {
+	job, err := commandAdapter.findJob(requester.Channel, id)
+	if err != nil {
+		return "", err
+	}
+
+	targets, err := commandAdapter.router.Targets(job.Routes)
+	if err != nil {
+		return "", err
+	}
+
+	if err = commandAdapter.assetService.Retry(ctx, requester, id, targets); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("Retrying *%s*, the progress is posted in the thread of the upload.", job.AssetFile.Name), nil
+}
+
+// cancel stops the upload, the failure is posted in the thread of the upload.
+func (commandAdapter *CommandAdapter) cancel(ctx context.Context, requester coremodel.Conversation, id string) (string, error) {
+	job, err := commandAdapter.findJob(requester.Channel, id)
+	if err != nil {
+		return "", err
+	}
+
+	if err = commandAdapter.assetService.Cancel(ctx, requester, id); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("Cancelling *%s*.", job.AssetFile.Name), nil
+}
+
+// findJob returns the upload only when it was posted in the channel, the
+// uploads of the other channels are not found.
+func (commandAdapter *CommandAdapter) findJob(channel, id string) (coremodel.Job, error) {
+	job, found, err := commandAdapter.assetService.FindJob(id)
+	if err != nil {
+		return coremodel.Job{}, err
+	}
+	if !found || job.AssetFile.Conversation.Channel != channel {
+		return coremodel.Job{}, coreservice.JobNotFoundError
+	}
+	return job, nil
+}
+
+func commandConversation(command slack.SlashCommand) coremodel.Conversation {
+	return coremodel.Conversation{
+		Platform: coremodel.PlatformSlack,
+		Channel:  command.ChannelID,
+		User:     command.UserID,
+	}
+}
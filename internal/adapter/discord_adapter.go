@@ -0,0 +1,254 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	coremodel "github.com/wallacehenriquesilva/slack-assets-bot/internal/core/model"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/core/port/in"
+	extmodel "github.com/wallacehenriquesilva/slack-assets-bot/internal/model"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/service"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/util/fileutil"
+	"strings"
+	"time"
+)
+
+// Discord limits the embeds, the messages get only what fits.
+const (
+	maxDiscordFieldText = 1024
+	maxDiscordButtons   = 5
+	maxDiscordEmbeds    = 10
+)
+
+var discordStageReactions = map[coremodel.Stage]string{
+	coremodel.StageReceived:         "👀",
+	coremodel.StageDownloading:      "📥",
+	coremodel.StageExtracting:       "📦",
+	coremodel.StageAwaitingApproval: "✋",
+	coremodel.StageCommitting:       "🛠️",
+	coremodel.StagePROpened:         "✅",
+	coremodel.StageFailed:           "❌",
+}
+
+// unicodeStatusIcons are the status icons of the chats without emoji names.
+var unicodeStatusIcons = statusIcons{done: "✅", running: "⏳", failed: "❌"}
+
+type DiscordAdapter struct {
+	discordService service.DiscordClient
+}
+
+func NewDiscordAdapter(discordService service.DiscordClient) in.MessageSystem {
+	return &DiscordAdapter{
+		discordService: discordService,
+	}
+}
+
+// PublishMessage replies to the upload with an embed, the pull requests as
+// link buttons and one more embed per image preview.
+func (discordAdapter *DiscordAdapter) PublishMessage(ctx context.Context, message coremodel.Message) error {
+	color := extmodel.DiscordError
+	if message.Style == coremodel.SuccessMessage {
+		color = extmodel.DiscordSuccess
+	}
+
+	embed := extmodel.DiscordEmbed{
+		Title:       message.Title,
+		Description: message.Message,
+		Color:       color,
+	}
+	outgoing := extmodel.DiscordOutgoingMessage{
+		MessageReference: reply(message.Conversation),
+	}
+	if message.Mention {
+		outgoing.Content = mention(message.Conversation)
+	}
+
+	details := message.Details
+	if details == nil {
+		outgoing.Embeds = []extmodel.DiscordEmbed{embed}
+		_, err := discordAdapter.discordService.PostMessage(ctx, message.Conversation.Channel, outgoing)
+		return err
+	}
+
+	if len(details.Files) > 0 {
+		embed.Fields = append(embed.Fields, extmodel.DiscordEmbedField{Name: "Files", Value: discordFiles(details.Files)})
+	}
+
+	var footer []string
+	if details.Uploader != "" {
+		footer = append(footer, "Uploaded by "+details.Uploader)
+	}
+	if details.Duration > 0 {
+		footer = append(footer, "Processed in "+details.Duration.Round(100*time.Millisecond).String())
+	}
+	if len(footer) > 0 {
+		embed.Footer = &extmodel.DiscordEmbedFooter{Text: strings.Join(footer, " • ")}
+	}
+
+	outgoing.Embeds = []extmodel.DiscordEmbed{embed}
+	for _, preview := range details.Previews {
+		if len(outgoing.Embeds) == maxDiscordEmbeds {
+			break
+		}
+		outgoing.Embeds = append(outgoing.Embeds, extmodel.DiscordEmbed{
+			Title: preview.Name,
+			Image: &extmodel.DiscordEmbedImage{Url: preview.Url},
+			Color: color,
+		})
+	}
+
+	var buttons []extmodel.DiscordComponent
+	for _, link := range details.Links {
+		if len(buttons) == maxDiscordButtons {
+			break
+		}
+		buttons = append(buttons, extmodel.DiscordComponent{
+			Type:  extmodel.DiscordButton,
+			Style: extmodel.DiscordButtonLink,
+			Label: truncateText(link.Label, 80),
+			Url:   link.Url,
+		})
+	}
+	if len(buttons) > 0 {
+		outgoing.Components = []extmodel.DiscordComponent{{Type: extmodel.DiscordActionRow, Components: buttons}}
+	}
+
+	_, err := discordAdapter.discordService.PostMessage(ctx, message.Conversation.Channel, outgoing)
+	return err
+}
+
+func (discordAdapter *DiscordAdapter) DownloadFile(ctx context.Context, file coremodel.MessageFile) (string, error) {
+	return discordAdapter.discordService.DownloadFile(ctx, file.Url, file.Extension, file.Size, file.MaxSize)
+}
+
+func (discordAdapter *DiscordAdapter) ReportStage(ctx context.Context, conversation coremodel.Conversation, previous, stage coremodel.Stage) error {
+	emoji, ok := discordStageReactions[stage]
+	if !ok || conversation.MessageID == "" {
+		return nil
+	}
+
+	if previousEmoji, ok := discordStageReactions[previous]; ok && previousEmoji != emoji {
+		if err := discordAdapter.discordService.RemoveReaction(ctx, conversation.Channel, conversation.MessageID, previousEmoji); err != nil {
+			return err
+		}
+	}
+	return discordAdapter.discordService.AddReaction(ctx, conversation.Channel, conversation.MessageID, emoji)
+}
+
+func (discordAdapter *DiscordAdapter) PublishStatus(ctx context.Context, conversation coremodel.Conversation, status coremodel.Status) (string, error) {
+	return discordAdapter.discordService.PostMessage(ctx, conversation.Channel, extmodel.DiscordOutgoingMessage{
+		Content:          formatStatus(status, unicodeStatusIcons),
+		MessageReference: reply(conversation),
+	})
+}
+
+func (discordAdapter *DiscordAdapter) UpdateStatus(ctx context.Context, conversation coremodel.Conversation, statusID string, status coremodel.Status) error {
+	return discordAdapter.discordService.EditMessage(ctx, conversation.Channel, statusID, extmodel.DiscordOutgoingMessage{
+		Content: formatStatus(status, unicodeStatusIcons),
+	})
+}
+
+// PublishEphemeral replies mentioning the user, Discord only has ephemeral
+// messages as answers to interactions.
+func (discordAdapter *DiscordAdapter) PublishEphemeral(ctx context.Context, conversation coremodel.Conversation, text string) error {
+	_, err := discordAdapter.discordService.PostMessage(ctx, conversation.Channel, extmodel.DiscordOutgoingMessage{
+		Content:          strings.TrimSpace(mention(conversation) + " " + text),
+		MessageReference: reply(conversation),
+	})
+	return err
+}
+
+func (discordAdapter *DiscordAdapter) RequestApproval(ctx context.Context, conversation coremodel.Conversation, request coremodel.ApprovalRequest) (string, error) {
+	embed := extmodel.DiscordEmbed{
+		Title:       "Review the upload before the pull request is opened",
+		Description: fmt.Sprintf("It expires <t:%d:R>.", request.ExpiresAt.Unix()),
+	}
+	if len(request.Files) > 0 {
+		embed.Fields = append(embed.Fields, extmodel.DiscordEmbedField{Name: "Files", Value: discordFiles(request.Files)})
+	}
+	if len(request.Warnings) > 0 {
+		warnings := "⚠️ " + strings.Join(request.Warnings, "\n⚠️ ")
+		embed.Fields = append(embed.Fields, extmodel.DiscordEmbedField{Name: "Warnings", Value: truncateText(warnings, maxDiscordFieldText)})
+	}
+
+	buttons := []extmodel.DiscordComponent{
+		{
+			Type:     extmodel.DiscordButton,
+			Style:    extmodel.DiscordButtonSuccess,
+			Label:    "Approve",
+			CustomID: extmodel.ApproveActionID + ":" + request.JobID,
+		},
+		{
+			Type:     extmodel.DiscordButton,
+			Style:    extmodel.DiscordButtonDanger,
+			Label:    "Cancel",
+			CustomID: extmodel.CancelActionID + ":" + request.JobID,
+		},
+	}
+
+	return discordAdapter.discordService.PostMessage(ctx, conversation.Channel, extmodel.DiscordOutgoingMessage{
+		Content:          mention(conversation),
+		Embeds:           []extmodel.DiscordEmbed{embed},
+		Components:       []extmodel.DiscordComponent{{Type: extmodel.DiscordActionRow, Components: buttons}},
+		MessageReference: reply(conversation),
+	})
+}
+
+// CloseApproval replaces the approval message with its outcome, removing the
+// buttons.
+func (discordAdapter *DiscordAdapter) CloseApproval(ctx context.Context, conversation coremodel.Conversation, approvalID string, result coremodel.ApprovalResult) error {
+	var text string
+	switch result.Outcome {
+	case coremodel.ApprovalApproved:
+		text = fmt.Sprintf("✅ Approved by <@%s>", result.User)
+	case coremodel.ApprovalCancelled:
+		text = fmt.Sprintf("🚫 Cancelled by <@%s>", result.User)
+	default:
+		text = "⌛ The approval expired"
+	}
+
+	return discordAdapter.discordService.EditMessage(ctx, conversation.Channel, approvalID, extmodel.DiscordOutgoingMessage{
+		Content: text,
+	})
+}
+
+func mention(conversation coremodel.Conversation) string {
+	if conversation.User == "" {
+		return ""
+	}
+	return fmt.Sprintf("<@%s>", conversation.User)
+}
+
+// reply makes the message an answer to the upload, which is how the bot
+// keeps the conversation together on Discord.
+func reply(conversation coremodel.Conversation) *extmodel.DiscordMessageReference {
+	if conversation.Thread == "" {
+		return nil
+	}
+	return &extmodel.DiscordMessageReference{MessageID: conversation.Thread}
+}
+
+// discordFiles renders the files as a code block that fits in an embed field.
+func discordFiles(files []coremodel.MessageFileEntry) string {
+	lines := fileLines(files)
+
+	var builder strings.Builder
+	for index, line := range lines {
+		entry := fmt.Sprintf("%s  %s\n", line.Path, fileutil.HumanSize(line.Size))
+		more := fmt.Sprintf("... and %d more\n", len(lines)-index)
+		if builder.Len()+len(entry)+len(more)+len("```\n```") > maxDiscordFieldText {
+			builder.WriteString(more)
+			break
+		}
+		builder.WriteString(entry)
+	}
+
+	return "```\n" + builder.String() + "```"
+}
+
+func truncateText(text string, length int) string {
+	runes := []rune(text)
+	if len(runes) <= length {
+		return text
+	}
+	return string(runes[:length-1]) + "…"
+}
@@ -0,0 +1,108 @@
+package adapter
+
+import (
+	"context"
+	coremodel "github.com/wallacehenriquesilva/slack-assets-bot/internal/core/model"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/core/port/in"
+	coreservice "github.com/wallacehenriquesilva/slack-assets-bot/internal/core/service"
+	extmodel "github.com/wallacehenriquesilva/slack-assets-bot/internal/model"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/service"
+	"log"
+	"path"
+	"strings"
+)
+
+// DiscordAssetAdapter turns the attachments posted in the Discord channels
+// into upload requests and the clicks on the approval buttons into decisions.
+type DiscordAssetAdapter struct {
+	intake         in.AssetIntake
+	assetService   coreservice.AssetSetvice
+	discordService service.DiscordClient
+}
+
+func NewDiscordAssetAdapter(intake in.AssetIntake, assetService coreservice.AssetSetvice,
+	discordService service.DiscordClient) *DiscordAssetAdapter {
+	return &DiscordAssetAdapter{
+		intake:         intake,
+		assetService:   assetService,
+		discordService: discordService,
+	}
+}
+
+func (discordAssetAdapter *DiscordAssetAdapter) ProcessMessage(ctx context.Context, message extmodel.DiscordMessage) error {
+	if message.Author.Bot || message.Author.ID == "" || len(message.Attachments) == 0 {
+		return nil
+	}
+
+	files := make([]coremodel.UploadFile, 0, len(message.Attachments))
+	for _, attachment := range message.Attachments {
+		files = append(files, coremodel.UploadFile{
+			ID:        attachment.ID,
+			Url:       attachment.Url,
+			Extension: strings.ToLower(strings.TrimPrefix(path.Ext(attachment.Filename), ".")),
+			Name:      attachment.Filename,
+			Size:      int64(attachment.Size),
+		})
+	}
+
+	name := message.Author.GlobalName
+	if name == "" {
+		name = message.Author.Username
+	}
+
+	return discordAssetAdapter.intake.Submit(ctx, coremodel.AssetUploadRequest{
+		DeliveryID: message.ID,
+		Requester:  coremodel.Requester{ID: message.Author.ID, Name: name},
+		Conversation: coremodel.Conversation{
+			Platform:  coremodel.PlatformDiscord,
+			Channel:   message.ChannelID,
+			Thread:    message.ID,
+			MessageID: message.ID,
+			User:      message.Author.ID,
+		},
+		Files:     files,
+		Text:      cleanText(message.Content),
+		Timestamp: message.Timestamp,
+	})
+}
+
+// ProcessInteraction handles the approval buttons, whose custom ID is the
+// action followed by the job ID.
+func (discordAssetAdapter *DiscordAssetAdapter) ProcessInteraction(ctx context.Context, interaction extmodel.DiscordInteraction) error {
+	if interaction.Type != extmodel.DiscordComponentInteraction {
+		return nil
+	}
+
+	parts := strings.SplitN(interaction.Data.CustomID, ":", 2)
+	if len(parts) != 2 || (parts[0] != extmodel.ApproveActionID && parts[0] != extmodel.CancelActionID) {
+		return nil
+	}
+	action, jobID := parts[0], parts[1]
+
+	if err := discordAssetAdapter.discordService.AcknowledgeInteraction(ctx, interaction); err != nil {
+		log.Printf("error to acknowledge the Discord interaction: %v\n", err)
+	}
+
+	user := interaction.User
+	if interaction.Member != nil {
+		user = &interaction.Member.User
+	}
+	if user == nil {
+		return nil
+	}
+
+	conversation := coremodel.Conversation{
+		Platform: coremodel.PlatformDiscord,
+		Channel:  interaction.ChannelID,
+		User:     user.ID,
+	}
+	if interaction.Message != nil && interaction.Message.MessageReference != nil {
+		conversation.Thread = interaction.Message.MessageReference.MessageID
+	}
+
+	return discordAssetAdapter.assetService.Decide(ctx, coremodel.ApprovalDecision{
+		JobID:        jobID,
+		Approved:     action == extmodel.ApproveActionID,
+		Conversation: conversation,
+	})
+}
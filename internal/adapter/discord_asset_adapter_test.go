@@ -0,0 +1,247 @@
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gorilla/websocket"
+	coremodel "github.com/wallacehenriquesilva/slack-assets-bot/internal/core/model"
+	coreservice "github.com/wallacehenriquesilva/slack-assets-bot/internal/core/service"
+	extmodel "github.com/wallacehenriquesilva/slack-assets-bot/internal/model"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/service"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testTimeout = 5 * time.Second
+
+type fakeIntake struct {
+	requests chan coremodel.AssetUploadRequest
+}
+
+func (intake *fakeIntake) Submit(ctx context.Context, request coremodel.AssetUploadRequest) error {
+	intake.requests <- request
+	return nil
+}
+
+// fakeAssetService only takes the decisions, the other methods are not
+// called by the Discord adapter.
+type fakeAssetService struct {
+	coreservice.AssetSetvice
+	decisions chan coremodel.ApprovalDecision
+}
+
+func (assetService *fakeAssetService) Decide(ctx context.Context, decision coremodel.ApprovalDecision) error {
+	assetService.decisions <- decision
+	return nil
+}
+
+type gatewayFrame struct {
+	Op int             `json:"op"`
+	D  json.RawMessage `json:"d"`
+	S  *int64          `json:"s,omitempty"`
+	T  string          `json:"t,omitempty"`
+}
+
+// fakeGateway plays the Discord side of the gateway: hello, identify, the
+// dispatch of a message and, once the heartbeat carries the last sequence,
+// the dispatch of a click on the approve button.
+type fakeGateway struct {
+	t          *testing.T
+	identified chan map[string]interface{}
+	heartbeats chan int64
+}
+
+func (gateway *fakeGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+	if err != nil {
+		gateway.t.Errorf("upgrade: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	gateway.send(conn, gatewayFrame{Op: 10, D: json.RawMessage(`{"heartbeat_interval":20}`)})
+
+	var identify gatewayFrame
+	if err = conn.ReadJSON(&identify); err != nil || identify.Op != 2 {
+		gateway.t.Errorf("expected identify, got op %d: %v", identify.Op, err)
+		return
+	}
+	var identifyData map[string]interface{}
+	_ = json.Unmarshal(identify.D, &identifyData)
+	gateway.identified <- identifyData
+
+	gateway.dispatch(conn, 1, "READY", `{"session_id":"session"}`)
+	gateway.dispatch(conn, 2, "MESSAGE_CREATE", `{
+		"id": "M1",
+		"channel_id": "C1",
+		"author": {"id": "U1", "username": "alice"},
+		"content": "new icons",
+		"timestamp": "2024-01-02T03:04:05Z",
+		"attachments": [{"id": "A1", "filename": "Icons.ZIP", "url": "https://cdn.example/icons.zip", "size": 42}]
+	}`)
+
+	for {
+		var frame gatewayFrame
+		if err = conn.ReadJSON(&frame); err != nil {
+			return
+		}
+		if frame.Op != 1 {
+			continue
+		}
+
+		var sequence int64
+		_ = json.Unmarshal(frame.D, &sequence)
+		gateway.heartbeats <- sequence
+		gateway.send(conn, gatewayFrame{Op: 11})
+
+		if sequence == 2 {
+			gateway.dispatch(conn, 3, "INTERACTION_CREATE", `{
+				"id": "I1",
+				"token": "interaction-token",
+				"type": 3,
+				"channel_id": "C1",
+				"member": {"user": {"id": "U2", "username": "bob"}},
+				"message": {"id": "M2", "message_reference": {"message_id": "M1"}},
+				"data": {"custom_id": "asset_approve:job-1"}
+			}`)
+		}
+	}
+}
+
+func (gateway *fakeGateway) dispatch(conn *websocket.Conn, sequence int64, event, data string) {
+	gateway.send(conn, gatewayFrame{Op: 0, S: &sequence, T: event, D: json.RawMessage(data)})
+}
+
+func (gateway *fakeGateway) send(conn *websocket.Conn, frame gatewayFrame) {
+	if frame.D == nil {
+		frame.D = json.RawMessage(`null`)
+	}
+	if err := conn.WriteJSON(frame); err != nil {
+		gateway.t.Errorf("write: %v", err)
+	}
+}
+
+func TestDiscordAdapterWithFakeGateway(t *testing.T) {
+	gateway := &fakeGateway{
+		t:          t,
+		identified: make(chan map[string]interface{}, 1),
+		heartbeats: make(chan int64, 100),
+	}
+	gatewayServer := httptest.NewServer(gateway)
+	defer gatewayServer.Close()
+
+	callbacks := make(chan string, 1)
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bot token" {
+			t.Errorf("unexpected authorization %q", r.Header.Get("Authorization"))
+		}
+		callbacks <- r.Method + " " + r.URL.Path
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer apiServer.Close()
+
+	intake := &fakeIntake{requests: make(chan coremodel.AssetUploadRequest, 1)}
+	assetService := &fakeAssetService{decisions: make(chan coremodel.ApprovalDecision, 1)}
+
+	discordService := service.NewDiscordClient("token", "ws"+strings.TrimPrefix(gatewayServer.URL, "http"), apiServer.URL,
+		&sync.WaitGroup{})
+	discordAssetAdapter := NewDiscordAssetAdapter(intake, assetService, discordService)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- discordService.StartGateway(ctx, service.DiscordHandlers{
+			Messages:     discordAssetAdapter.ProcessMessage,
+			Interactions: discordAssetAdapter.ProcessInteraction,
+		})
+	}()
+
+	select {
+	case identify := <-gateway.identified:
+		if identify["token"] != "token" {
+			t.Errorf("identified with the token %v", identify["token"])
+		}
+		if intents, _ := identify["intents"].(float64); int(intents)&(1<<15) == 0 {
+			t.Errorf("identified without the message content intent: %v", identify["intents"])
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("the bot didn't identify")
+	}
+
+	select {
+	case request := <-intake.requests:
+		if request.DeliveryID != "M1" || request.Requester.ID != "U1" || request.Requester.Name != "alice" {
+			t.Errorf("unexpected request %+v", request)
+		}
+		want := coremodel.Conversation{Platform: coremodel.PlatformDiscord, Channel: "C1", Thread: "M1", MessageID: "M1", User: "U1"}
+		if request.Conversation != want {
+			t.Errorf("conversation %+v, want %+v", request.Conversation, want)
+		}
+		if len(request.Files) != 1 || request.Files[0].Extension != "zip" || request.Files[0].Size != 42 {
+			t.Errorf("unexpected files %+v", request.Files)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("the message was not submitted")
+	}
+
+	select {
+	case decision := <-assetService.decisions:
+		if decision.JobID != "job-1" || !decision.Approved {
+			t.Errorf("unexpected decision %+v", decision)
+		}
+		want := coremodel.Conversation{Platform: coremodel.PlatformDiscord, Channel: "C1", Thread: "M1", User: "U2"}
+		if decision.Conversation != want {
+			t.Errorf("conversation %+v, want %+v", decision.Conversation, want)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("the interaction was not decided")
+	}
+
+	select {
+	case callback := <-callbacks:
+		if callback != "POST /interactions/I1/interaction-token/callback" {
+			t.Errorf("unexpected callback %s", callback)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("the interaction was not acknowledged")
+	}
+
+	cancel()
+	select {
+	case err := <-stopped:
+		if err != nil {
+			t.Errorf("the gateway stopped with %v", err)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("the gateway didn't stop with its context")
+	}
+}
+
+func TestDiscordAdapterIgnoresBotsAndOtherButtons(t *testing.T) {
+	intake := &fakeIntake{requests: make(chan coremodel.AssetUploadRequest, 1)}
+	assetService := &fakeAssetService{decisions: make(chan coremodel.ApprovalDecision, 1)}
+	discordAssetAdapter := NewDiscordAssetAdapter(intake, assetService, nil)
+
+	bot := extmodel.DiscordMessage{
+		ID:          "M1",
+		Author:      extmodel.DiscordUser{ID: "B1", Bot: true},
+		Attachments: []extmodel.DiscordAttachment{{ID: "A1", Filename: "icons.zip"}},
+	}
+	if err := discordAssetAdapter.ProcessMessage(context.Background(), bot); err != nil {
+		t.Fatal(err)
+	}
+
+	other := extmodel.DiscordInteraction{Type: extmodel.DiscordComponentInteraction}
+	other.Data.CustomID = "something_else:job-1"
+	if err := discordAssetAdapter.ProcessInteraction(context.Background(), other); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(intake.requests) != 0 || len(assetService.decisions) != 0 {
+		t.Errorf("expected nothing, got %d requests and %d decisions", len(intake.requests), len(assetService.decisions))
+	}
+}
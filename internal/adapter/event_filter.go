@@ -0,0 +1,46 @@
+package adapter
+
+import (
+	extmodel "github.com/wallacehenriquesilva/slack-assets-bot/internal/model"
+)
+
+// EventFilter decides which Slack events are uploads for the bot. Edits,
+// deletions, bot messages (including its own), messages in channels without a
+// route and messages without files are ignored.
+type EventFilter struct {
+	channels  map[string]bool
+	botUserID string
+}
+
+var acceptedSubTypes = map[string]bool{
+	"":           true,
+	"file_share": true,
+}
+
+func NewEventFilter(channels []string, botUserID string) *EventFilter {
+	filter := &EventFilter{
+		channels:  make(map[string]bool, len(channels)),
+		botUserID: botUserID,
+	}
+
+	for _, channel := range channels {
+		filter.channels[channel] = true
+	}
+
+	return filter
+}
+
+func (filter *EventFilter) Accept(event extmodel.Event) bool {
+	switch {
+	case event.Type != "message":
+		return false
+	case !acceptedSubTypes[event.SubType]:
+		return false
+	case event.BotID != "" || event.User == "" || event.User == filter.botUserID:
+		return false
+	case !filter.channels[event.Channel]:
+		return false
+	}
+
+	return len(event.Files) > 0
+}
@@ -0,0 +1,133 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	coremodel "github.com/wallacehenriquesilva/slack-assets-bot/internal/core/model"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/core/port/in"
+	coreservice "github.com/wallacehenriquesilva/slack-assets-bot/internal/core/service"
+	extmodel "github.com/wallacehenriquesilva/slack-assets-bot/internal/model"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/service"
+	"log"
+	"path"
+	"strings"
+	"time"
+)
+
+// FormAdapter opens the upload form and submits it to the intake as the
+// uploads posted in the channels are.
+type FormAdapter struct {
+	assetIntake  in.AssetIntake
+	slackService service.SlackClient
+	router       *Router
+	categories   []string
+}
+
+func NewFormAdapter(assetIntake in.AssetIntake, slackService service.SlackClient, router *Router,
+	categories []string) *FormAdapter {
+	return &FormAdapter{
+		assetIntake:  assetIntake,
+		slackService: slackService,
+		router:       router,
+		categories:   categories,
+	}
+}
+
+func (formAdapter *FormAdapter) Open(ctx context.Context, triggerID string) error {
+	routes := formAdapter.router.Routes()
+
+	form := extmodel.SlackUploadForm{
+		Routes:     make([]string, 0, len(routes)),
+		Categories: formAdapter.categories,
+	}
+	for _, route := range routes {
+		form.Routes = append(form.Routes, route.Name)
+	}
+
+	return formAdapter.slackService.OpenUploadForm(ctx, triggerID, form)
+}
+
+// Submit validates the form and starts the job in the background, so the
+// modal is closed right away. The job reports in a thread under a message
+// posted in the channel of the route.
+func (formAdapter *FormAdapter) Submit(ctx context.Context, submission extmodel.SlackUploadSubmission) (map[string]string, error) {
+	targets, err := formAdapter.router.Targets([]string{submission.Route})
+	if err != nil {
+		return map[string]string{extmodel.FormRouteBlockID: err.Error()}, nil
+	}
+	if len(submission.Files) != 1 {
+		return map[string]string{extmodel.FormFilesBlockID: MaxNumberofFilesError.Error()}, nil
+	}
+
+	route := targets[0].Route
+	file := submission.Files[0]
+
+	request := coremodel.AssetUploadRequest{
+		Requester: coremodel.Requester{
+			ID:   submission.UserID,
+			Name: userName(ctx, formAdapter.slackService, submission.UserID),
+		},
+		Route: route.Name,
+		Files: []coremodel.UploadFile{{
+			ID:        file.ID,
+			Url:       file.Url,
+			Extension: file.FileType,
+			Name:      file.Name,
+			Size:      int64(file.Size),
+		}},
+		Text:      formText(submission, route.TargetPath),
+		Timestamp: time.Now(),
+	}
+
+	if err = coreservice.ValidateAssetFile(assetFileOf(request)); err != nil {
+		return map[string]string{formBlockOf(err): err.Error()}, nil
+	}
+
+	text := fmt.Sprintf("<@%s> uploaded *%s* with the upload form", submission.UserID, file.Name)
+	timestamp, err := formAdapter.slackService.PostText(ctx, route.Channel, "", text)
+	if err != nil {
+		return nil, err
+	}
+
+	request.Conversation = coremodel.Conversation{
+		Platform:  coremodel.PlatformSlack,
+		Channel:   route.Channel,
+		Thread:    timestamp,
+		MessageID: timestamp,
+		User:      submission.UserID,
+	}
+
+	go func() {
+		if err := formAdapter.assetIntake.Submit(ctx, request); err != nil {
+			log.Printf("error to process the upload form of %s: %v\n", submission.UserID, err)
+		}
+	}()
+
+	return nil, nil
+}
+
+// formText writes the fields of the form as the message of an upload, with
+// the category under the target path of the route and the reviewers as
+// directives.
+func formText(submission extmodel.SlackUploadSubmission, routeTargetPath string) string {
+	lines := []string{strings.TrimSpace(submission.Title), strings.TrimSpace(submission.Description)}
+	if submission.Category != "" {
+		lines = append(lines, "path: "+path.Join(routeTargetPath, submission.Category))
+	}
+	if reviewers := coreservice.SplitReviewers(submission.Reviewers); len(reviewers) > 0 {
+		lines = append(lines, "reviewers: "+strings.Join(reviewers, ", "))
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// formBlockOf returns the field of the form an error of the upload is about.
+func formBlockOf(err error) string {
+	if errors.Is(err, coreservice.InvalidTargetPathError) {
+		return extmodel.FormCategoryBlockID
+	}
+	if errors.Is(err, coreservice.ChatMentionReviewerError) {
+		return extmodel.FormReviewersBlockID
+	}
+	return extmodel.FormFilesBlockID
+}
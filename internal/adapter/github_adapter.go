@@ -1,8 +1,12 @@
 package adapter
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/wallacehenriquesilva/slack-assets-bot/internal/core/model"
 	"github.com/wallacehenriquesilva/slack-assets-bot/internal/core/port/out"
+	coreservice "github.com/wallacehenriquesilva/slack-assets-bot/internal/core/service"
 	extmodel "github.com/wallacehenriquesilva/slack-assets-bot/internal/model"
 	"github.com/wallacehenriquesilva/slack-assets-bot/internal/service"
 )
@@ -17,7 +21,8 @@ func NewGithubAdapter(githubService service.GithubClient) out.VersionControlSyst
 	}
 }
 
-func (githubAdapter *GithubAdapter) CreateCommit(commitBranch, baseBranch, message string, sourceFiles []model.VCSFile) error {
+func (githubAdapter *GithubAdapter) CreateCommit(ctx context.Context, commitBranch, baseBranch string, reuseBranch bool, message string,
+	sourceFiles []model.VCSFile) (bool, error) {
 	githubFiles := make([]extmodel.GithubFile, 0, len(sourceFiles)+1)
 
 	for _, file := range sourceFiles {
@@ -28,9 +33,81 @@ func (githubAdapter *GithubAdapter) CreateCommit(commitBranch, baseBranch, messa
 		githubFiles = append(githubFiles, githubFile)
 	}
 
-	return githubAdapter.githubService.CreateCommit(commitBranch, baseBranch, message, githubFiles)
+	created, err := githubAdapter.githubService.CreateCommit(ctx, commitBranch, baseBranch, reuseBranch, message, githubFiles)
+	if errors.Is(err, service.BranchExistsError) {
+		return created, fmt.Errorf("%w: %s", coreservice.BranchExistsError, commitBranch)
+	}
+	return created, githubError(err)
+}
+
+func (githubAdapter *GithubAdapter) CreatePullRequest(ctx context.Context, headBranch, baseBranch, title, description string, reviewers []string) (model.PullRequest, error) {
+	pullRequest, err := githubAdapter.githubService.CreatePullRequest(ctx, headBranch, baseBranch, title, description, reviewers)
+	if err != nil {
+		return model.PullRequest{}, githubError(err)
+	}
+
+	return pullRequestOf(pullRequest), nil
+}
+
+func (githubAdapter *GithubAdapter) FindPullRequest(ctx context.Context, headBranch string) (model.PullRequest, bool, error) {
+	pullRequest, found, err := githubAdapter.githubService.FindPullRequest(ctx, headBranch)
+	if err != nil || !found {
+		return model.PullRequest{}, found, githubError(err)
+	}
+
+	return pullRequestOf(pullRequest), true, nil
+}
+
+func (githubAdapter *GithubAdapter) ListBranches(ctx context.Context, prefix string) ([]model.Branch, error) {
+	githubBranches, err := githubAdapter.githubService.ListBranches(ctx, prefix)
+	if err != nil {
+		return nil, githubError(err)
+	}
+
+	branches := make([]model.Branch, 0, len(githubBranches))
+	for _, branch := range githubBranches {
+		branches = append(branches, model.Branch{Name: branch.Name, CommittedAt: branch.CommittedAt})
+	}
+	return branches, nil
+}
+
+func (githubAdapter *GithubAdapter) DeleteBranch(ctx context.Context, branch string) error {
+	return githubError(githubAdapter.githubService.DeleteBranch(ctx, branch))
+}
+
+func pullRequestOf(pullRequest extmodel.GithubPullRequest) model.PullRequest {
+	return model.PullRequest{
+		Repository: pullRequest.Repository,
+		Number:     pullRequest.Number,
+		Url:        pullRequest.HTMLURL,
+		NodeID:     pullRequest.NodeID,
+		HeadBranch: pullRequest.HeadBranch,
+		HeadSHA:    pullRequest.HeadSHA,
+	}
+}
+
+func (githubAdapter *GithubAdapter) EnableAutoMerge(ctx context.Context, pullRequest model.PullRequest, method model.MergeMethod) error {
+	return githubError(githubAdapter.githubService.EnableAutoMerge(ctx, pullRequest.NodeID, string(method)))
+}
+
+func (githubAdapter *GithubAdapter) GetChecksStatus(ctx context.Context, pullRequest model.PullRequest) (model.ChecksStatus, error) {
+	status, err := githubAdapter.githubService.GetChecksStatus(ctx, pullRequest.HeadSHA)
+	if err != nil {
+		return model.ChecksStatus{}, githubError(err)
+	}
+
+	return model.ChecksStatus{
+		State:  model.ChecksState(status.State),
+		Total:  status.Total,
+		Failed: status.Failed,
+	}, nil
+}
+
+func (githubAdapter *GithubAdapter) FileURL(ctx context.Context, pullRequest model.PullRequest, remotePath string) (string, error) {
+	url, err := githubAdapter.githubService.FileURL(ctx, pullRequest.HeadBranch, remotePath)
+	return url, githubError(err)
 }
 
-func (githubAdapter *GithubAdapter) CreatePullRequest(headBranch, baseBranch, title, description string) (string, error) {
-	return githubAdapter.githubService.CreatePullRequest(headBranch, baseBranch, title, description)
+func (githubAdapter *GithubAdapter) MergePullRequest(ctx context.Context, pullRequest model.PullRequest, method model.MergeMethod) error {
+	return githubError(githubAdapter.githubService.MergePullRequest(ctx, pullRequest.Number, pullRequest.HeadSHA, string(method)))
 }
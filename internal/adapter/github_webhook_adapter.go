@@ -0,0 +1,67 @@
+package adapter
+
+import (
+	"context"
+	coremodel "github.com/wallacehenriquesilva/slack-assets-bot/internal/core/model"
+	coreservice "github.com/wallacehenriquesilva/slack-assets-bot/internal/core/service"
+	extmodel "github.com/wallacehenriquesilva/slack-assets-bot/internal/model"
+)
+
+type GithubWebhookAdapter struct {
+	notificationService coreservice.NotificationService
+}
+
+func NewGithubWebhookAdapter(notificationService coreservice.NotificationService) *GithubWebhookAdapter {
+	return &GithubWebhookAdapter{
+		notificationService: notificationService,
+	}
+}
+
+// Process translates the webhook deliveries worth a notification into pull
+// request events. Every other delivery is ignored.
+func (githubWebhookAdapter *GithubWebhookAdapter) Process(ctx context.Context, event extmodel.GithubWebhookEvent) error {
+	kind, ok := eventKind(event)
+	if !ok {
+		return nil
+	}
+
+	return githubWebhookAdapter.notificationService.Notify(ctx, coremodel.PullRequestEvent{
+		Kind:       kind,
+		Repository: event.Repository,
+		Branch:     event.Branch,
+		Url:        event.Url,
+		Actor:      event.Sender,
+	})
+}
+
+func eventKind(event extmodel.GithubWebhookEvent) (coremodel.PullRequestEventKind, bool) {
+	switch event.Type {
+	case "pull_request":
+		if event.Action != "closed" {
+			return "", false
+		}
+		if event.Merged {
+			return coremodel.PullRequestMerged, true
+		}
+		return coremodel.PullRequestClosed, true
+	case "pull_request_review":
+		if event.Action != "submitted" {
+			return "", false
+		}
+		switch event.ReviewState {
+		case "approved":
+			return coremodel.PullRequestApproved, true
+		case "changes_requested":
+			return coremodel.PullRequestChangesRequested, true
+		}
+	case "check_suite":
+		if event.Action != "completed" {
+			return "", false
+		}
+		switch event.Conclusion {
+		case "failure", "timed_out", "cancelled", "action_required":
+			return coremodel.PullRequestChecksFailed, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,81 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	coremodel "github.com/wallacehenriquesilva/slack-assets-bot/internal/core/model"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/core/port/in"
+	coreservice "github.com/wallacehenriquesilva/slack-assets-bot/internal/core/service"
+)
+
+// IntakeAdapter routes the upload requests of every chat and turns them into
+// asset jobs. The uploads of channels without a route are ignored.
+type IntakeAdapter struct {
+	assetService coreservice.AssetSetvice
+	router       *Router
+}
+
+func NewIntakeAdapter(assetService coreservice.AssetSetvice, router *Router) in.AssetIntake {
+	return &IntakeAdapter{
+		assetService: assetService,
+		router:       router,
+	}
+}
+
+func (intakeAdapter *IntakeAdapter) Submit(ctx context.Context, request coremodel.AssetUploadRequest) error {
+	if len(request.Files) == 0 {
+		return nil
+	}
+
+	targets, err := intakeAdapter.targets(request)
+	if errors.Is(err, NoRouteError) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(request.Files) > 1 {
+		_ = intakeAdapter.assetService.SendErrorMessage(ctx, request.Conversation, MaxNumberofFilesError)
+		return MaxNumberofFilesError
+	}
+
+	return intakeAdapter.assetService.Process(ctx, assetFileOf(request), targets)
+}
+
+// targets returns the targets of the route the request picked, or of the
+// route of its channel.
+func (intakeAdapter *IntakeAdapter) targets(request coremodel.AssetUploadRequest) ([]coreservice.Target, error) {
+	if request.Route != "" {
+		return intakeAdapter.router.Targets([]string{request.Route})
+	}
+
+	channel := request.Channel
+	if channel == "" {
+		channel = request.Conversation.Channel
+	}
+	return intakeAdapter.router.Route(channel, request.Text)
+}
+
+// assetFileOf returns the upload of the first file of the request.
+func assetFileOf(request coremodel.AssetUploadRequest) coremodel.AssetFile {
+	uploader := request.Requester.Name
+	if uploader == "" {
+		uploader = request.Requester.ID
+	}
+
+	file := request.Files[0]
+	return coremodel.AssetFile{
+		FileID:       file.ID,
+		DeliveryID:   request.DeliveryID,
+		Url:          file.Url,
+		Extension:    file.Extension,
+		Name:         file.Name,
+		Size:         file.Size,
+		Uploader:     uploader,
+		Text:         request.Text,
+		Message:      coreservice.ParseAssetMessage(request.Text),
+		Conversation: request.Conversation,
+		Timestamp:    request.Timestamp,
+	}
+}
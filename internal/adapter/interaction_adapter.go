@@ -0,0 +1,52 @@
+package adapter
+
+import (
+	"context"
+	"github.com/slack-go/slack"
+	coremodel "github.com/wallacehenriquesilva/slack-assets-bot/internal/core/model"
+	coreservice "github.com/wallacehenriquesilva/slack-assets-bot/internal/core/service"
+	extmodel "github.com/wallacehenriquesilva/slack-assets-bot/internal/model"
+)
+
+type InteractionAdapter struct {
+	assetService coreservice.AssetSetvice
+	formAdapter  *FormAdapter
+}
+
+func NewInteractionAdapter(assetService coreservice.AssetSetvice, formAdapter *FormAdapter) *InteractionAdapter {
+	return &InteractionAdapter{
+		assetService: assetService,
+		formAdapter:  formAdapter,
+	}
+}
+
+// Process opens the upload form from its shortcut and turns the clicks on the
+// approval buttons into decisions. Every other interaction is ignored.
+func (interactionAdapter *InteractionAdapter) Process(ctx context.Context, callback slack.InteractionCallback) error {
+	if callback.Type == slack.InteractionTypeShortcut && callback.CallbackID == extmodel.UploadShortcutID {
+		return interactionAdapter.formAdapter.Open(ctx, callback.TriggerID)
+	}
+
+	if callback.Type != slack.InteractionTypeBlockActions {
+		return nil
+	}
+
+	for _, action := range callback.ActionCallback.BlockActions {
+		if action.ActionID != extmodel.ApproveActionID && action.ActionID != extmodel.CancelActionID {
+			continue
+		}
+
+		return interactionAdapter.assetService.Decide(ctx, coremodel.ApprovalDecision{
+			JobID:    action.Value,
+			Approved: action.ActionID == extmodel.ApproveActionID,
+			Conversation: coremodel.Conversation{
+				Platform: coremodel.PlatformSlack,
+				Channel:  callback.Container.ChannelID,
+				Thread:   callback.Container.ThreadTs,
+				User:     callback.User.ID,
+			},
+		})
+	}
+
+	return nil
+}
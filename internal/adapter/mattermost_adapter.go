@@ -0,0 +1,191 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	coremodel "github.com/wallacehenriquesilva/slack-assets-bot/internal/core/model"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/core/port/in"
+	extmodel "github.com/wallacehenriquesilva/slack-assets-bot/internal/model"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/service"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/util/fileutil"
+	"log"
+	"strings"
+	"time"
+)
+
+const maxMarkdownFiles = 20
+
+// MattermostAdapter answers in the thread of the upload. Mattermost reads
+// the emoji names and the markdown of Slack, so the stages and the status
+// are the same.
+type MattermostAdapter struct {
+	mattermostService service.MattermostClient
+}
+
+func NewMattermostAdapter(mattermostService service.MattermostClient) in.MessageSystem {
+	return &MattermostAdapter{
+		mattermostService: mattermostService,
+	}
+}
+
+// PublishMessage posts the message as an attachment, with the pull requests
+// as links and one more attachment per image preview.
+func (mattermostAdapter *MattermostAdapter) PublishMessage(ctx context.Context, message coremodel.Message) error {
+	color := extmodel.MattermostError
+	if message.Style == coremodel.SuccessMessage {
+		color = extmodel.MattermostSuccess
+	}
+
+	attachment := extmodel.MattermostAttachment{
+		Color:   color,
+		Pretext: message.Title,
+		Text:    message.Message,
+	}
+	attachments := []extmodel.MattermostAttachment{attachment}
+
+	if details := message.Details; details != nil {
+		var links []string
+		for _, link := range details.Links {
+			links = append(links, fmt.Sprintf("[%s](%s)", link.Label, link.Url))
+		}
+		if len(links) > 0 {
+			attachments[0].Text += "\n\n" + strings.Join(links, " • ")
+		}
+
+		if len(details.Files) > 0 {
+			attachments[0].Text += "\n\n" + markdownFiles(details.Files)
+		}
+
+		var footer []string
+		if details.Uploader != "" {
+			footer = append(footer, "Uploaded by "+details.Uploader)
+		}
+		if details.Duration > 0 {
+			footer = append(footer, "Processed in "+details.Duration.Round(100*time.Millisecond).String())
+		}
+		attachments[0].Footer = strings.Join(footer, " • ")
+
+		for _, preview := range details.Previews {
+			attachments = append(attachments, extmodel.MattermostAttachment{
+				Color:    color,
+				Title:    preview.Name,
+				ImageURL: preview.Url,
+			})
+		}
+	}
+
+	post := extmodel.MattermostPost{
+		ChannelID: message.Conversation.Channel,
+		RootID:    message.Conversation.Thread,
+		Props:     map[string]interface{}{"attachments": attachments},
+	}
+	if message.Mention {
+		post.Message = mattermostAdapter.mention(ctx, message.Conversation.User)
+	}
+
+	_, err := mattermostAdapter.mattermostService.CreatePost(ctx, post)
+	return err
+}
+
+func (mattermostAdapter *MattermostAdapter) DownloadFile(ctx context.Context, file coremodel.MessageFile) (string, error) {
+	return mattermostAdapter.mattermostService.DownloadFile(ctx, file.Url, file.Extension, file.Size, file.MaxSize)
+}
+
+func (mattermostAdapter *MattermostAdapter) ReportStage(ctx context.Context, conversation coremodel.Conversation, previous, stage coremodel.Stage) error {
+	reaction, ok := stageReactions[stage]
+	if !ok || conversation.MessageID == "" {
+		return nil
+	}
+
+	if previousReaction, ok := stageReactions[previous]; ok && previousReaction != reaction {
+		if err := mattermostAdapter.mattermostService.RemoveReaction(ctx, conversation.MessageID, previousReaction); err != nil {
+			return err
+		}
+	}
+	return mattermostAdapter.mattermostService.AddReaction(ctx, conversation.MessageID, reaction)
+}
+
+func (mattermostAdapter *MattermostAdapter) PublishStatus(ctx context.Context, conversation coremodel.Conversation, status coremodel.Status) (string, error) {
+	return mattermostAdapter.mattermostService.CreatePost(ctx, extmodel.MattermostPost{
+		ChannelID: conversation.Channel,
+		RootID:    conversation.Thread,
+		Message:   statusText(status),
+	})
+}
+
+func (mattermostAdapter *MattermostAdapter) UpdateStatus(ctx context.Context, conversation coremodel.Conversation, statusID string, status coremodel.Status) error {
+	return mattermostAdapter.mattermostService.UpdatePost(ctx, statusID, extmodel.MattermostPost{
+		Message: statusText(status),
+	})
+}
+
+func (mattermostAdapter *MattermostAdapter) PublishEphemeral(ctx context.Context, conversation coremodel.Conversation, text string) error {
+	return mattermostAdapter.mattermostService.SendEphemeral(ctx, conversation.User, extmodel.MattermostPost{
+		ChannelID: conversation.Channel,
+		RootID:    conversation.Thread,
+		Message:   text,
+	})
+}
+
+func (mattermostAdapter *MattermostAdapter) RequestApproval(ctx context.Context, conversation coremodel.Conversation, request coremodel.ApprovalRequest) (string, error) {
+	lines := []string{
+		strings.TrimSpace(fmt.Sprintf("%s Review the upload before the pull request is opened. It expires at %s.",
+			mattermostAdapter.mention(ctx, conversation.User), request.ExpiresAt.Format(time.Kitchen))),
+	}
+	if len(request.Files) > 0 {
+		lines = append(lines, markdownFiles(request.Files))
+	}
+	for _, warning := range request.Warnings {
+		lines = append(lines, ":warning: "+warning)
+	}
+
+	return mattermostAdapter.mattermostService.PostApproval(ctx, extmodel.MattermostApproval{
+		ChannelID: conversation.Channel,
+		RootID:    conversation.Thread,
+		Text:      strings.Join(lines, "\n\n"),
+		JobID:     request.JobID,
+	})
+}
+
+// CloseApproval replaces the approval post with its outcome, removing the
+// buttons.
+func (mattermostAdapter *MattermostAdapter) CloseApproval(ctx context.Context, conversation coremodel.Conversation, approvalID string, result coremodel.ApprovalResult) error {
+	var text string
+	switch result.Outcome {
+	case coremodel.ApprovalApproved:
+		text = fmt.Sprintf(":white_check_mark: Approved by %s", mattermostAdapter.mention(ctx, result.User))
+	case coremodel.ApprovalCancelled:
+		text = fmt.Sprintf(":no_entry_sign: Cancelled by %s", mattermostAdapter.mention(ctx, result.User))
+	default:
+		text = ":hourglass: The approval expired"
+	}
+
+	return mattermostAdapter.mattermostService.UpdatePost(ctx, approvalID, extmodel.MattermostPost{Message: text})
+}
+
+// mention looks up the username of the user, Mattermost mentions have no
+// syntax for the user ID.
+func (mattermostAdapter *MattermostAdapter) mention(ctx context.Context, userID string) string {
+	if userID == "" {
+		return ""
+	}
+
+	user, err := mattermostAdapter.mattermostService.GetUser(ctx, userID)
+	if err != nil {
+		log.Printf("error to get the Mattermost user %s: %v\n", userID, err)
+		return ""
+	}
+	return "@" + user.Username
+}
+
+func markdownFiles(files []coremodel.MessageFileEntry) string {
+	lines := []string{"| File | Size |", "| --- | --- |"}
+	for index, line := range fileLines(files) {
+		if index == maxMarkdownFiles {
+			lines = append(lines, fmt.Sprintf("| ... and %d more | |", len(files)-maxMarkdownFiles))
+			break
+		}
+		lines = append(lines, fmt.Sprintf("| `%s` | %s |", line.Path, fileutil.HumanSize(line.Size)))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,113 @@
+package adapter
+
+import (
+	"context"
+	coremodel "github.com/wallacehenriquesilva/slack-assets-bot/internal/core/model"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/core/port/in"
+	coreservice "github.com/wallacehenriquesilva/slack-assets-bot/internal/core/service"
+	extmodel "github.com/wallacehenriquesilva/slack-assets-bot/internal/model"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/service"
+	"log"
+	"strings"
+	"time"
+)
+
+// MattermostAssetAdapter turns the files posted in the Mattermost channels
+// into upload requests and the clicks on the approval buttons into decisions.
+type MattermostAssetAdapter struct {
+	intake            in.AssetIntake
+	assetService      coreservice.AssetSetvice
+	mattermostService service.MattermostClient
+}
+
+func NewMattermostAssetAdapter(intake in.AssetIntake, assetService coreservice.AssetSetvice,
+	mattermostService service.MattermostClient) *MattermostAssetAdapter {
+	return &MattermostAssetAdapter{
+		intake:            intake,
+		assetService:      assetService,
+		mattermostService: mattermostService,
+	}
+}
+
+func (mattermostAssetAdapter *MattermostAssetAdapter) ProcessPost(ctx context.Context, post extmodel.MattermostPost) error {
+	if len(post.FileIDs) == 0 || post.UserID == "" {
+		return nil
+	}
+
+	thread := post.RootID
+	if thread == "" {
+		thread = post.ID
+	}
+
+	conversation := coremodel.Conversation{
+		Platform:  coremodel.PlatformMattermost,
+		Channel:   post.ChannelID,
+		Thread:    thread,
+		MessageID: post.ID,
+		User:      post.UserID,
+	}
+
+	files := make([]coremodel.UploadFile, 0, len(post.FileIDs))
+	for _, fileID := range post.FileIDs {
+		info, err := mattermostAssetAdapter.mattermostService.GetFileInfo(ctx, fileID)
+		if err != nil {
+			_ = mattermostAssetAdapter.assetService.SendErrorMessage(ctx, conversation, err)
+			return err
+		}
+
+		files = append(files, coremodel.UploadFile{
+			ID:        info.ID,
+			Url:       mattermostAssetAdapter.mattermostService.FileURL(info.ID),
+			Extension: strings.ToLower(info.Extension),
+			Name:      info.Name,
+			Size:      info.Size,
+		})
+	}
+
+	return mattermostAssetAdapter.intake.Submit(ctx, coremodel.AssetUploadRequest{
+		DeliveryID: post.ID,
+		Requester: coremodel.Requester{
+			ID:   post.UserID,
+			Name: mattermostAssetAdapter.userName(ctx, post.UserID),
+		},
+		Conversation: conversation,
+		Files:        files,
+		Text:         post.Message,
+		Timestamp:    time.Unix(0, post.CreateAt*int64(time.Millisecond)),
+	})
+}
+
+func (mattermostAssetAdapter *MattermostAssetAdapter) ProcessDecision(ctx context.Context, decision extmodel.MattermostDecision) error {
+	if decision.Action != extmodel.ApproveActionID && decision.Action != extmodel.CancelActionID {
+		return nil
+	}
+
+	return mattermostAssetAdapter.assetService.Decide(ctx, coremodel.ApprovalDecision{
+		JobID:    decision.JobID,
+		Approved: decision.Action == extmodel.ApproveActionID,
+		Conversation: coremodel.Conversation{
+			Platform: coremodel.PlatformMattermost,
+			Channel:  decision.ChannelID,
+			Thread:   decision.RootID,
+			User:     decision.UserID,
+		},
+	})
+}
+
+// userName prefers the full name over the nickname and the username, falling
+// back to the user ID when the bot can't read the user.
+func (mattermostAssetAdapter *MattermostAssetAdapter) userName(ctx context.Context, userID string) string {
+	user, err := mattermostAssetAdapter.mattermostService.GetUser(ctx, userID)
+	if err != nil {
+		log.Printf("error to get the Mattermost user %s: %v\n", userID, err)
+		return userID
+	}
+
+	fullName := strings.TrimSpace(user.FirstName + " " + user.LastName)
+	for _, name := range []string{fullName, user.Nickname, user.Username} {
+		if name != "" {
+			return name
+		}
+	}
+	return userID
+}
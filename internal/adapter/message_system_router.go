@@ -0,0 +1,101 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	coremodel "github.com/wallacehenriquesilva/slack-assets-bot/internal/core/model"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/core/port/in"
+)
+
+var UnknownPlatformError = fmt.Errorf("no message system is configured for the platform")
+
+// MessageSystemRouter answers every conversation through the message system
+// of its platform, so a single asset service serves all the chats.
+type MessageSystemRouter struct {
+	systems map[coremodel.Platform]in.MessageSystem
+}
+
+func NewMessageSystemRouter(systems map[coremodel.Platform]in.MessageSystem) in.MessageSystem {
+	return &MessageSystemRouter{
+		systems: systems,
+	}
+}
+
+// system returns the message system of the platform. The conversations
+// recorded before the platforms existed are all from Slack.
+func (messageSystemRouter *MessageSystemRouter) system(platform coremodel.Platform) (in.MessageSystem, error) {
+	if platform == "" {
+		platform = coremodel.PlatformSlack
+	}
+
+	system, ok := messageSystemRouter.systems[platform]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", UnknownPlatformError, platform)
+	}
+	return system, nil
+}
+
+func (messageSystemRouter *MessageSystemRouter) PublishMessage(ctx context.Context, message coremodel.Message) error {
+	system, err := messageSystemRouter.system(message.Conversation.Platform)
+	if err != nil {
+		return err
+	}
+	return system.PublishMessage(ctx, message)
+}
+
+func (messageSystemRouter *MessageSystemRouter) DownloadFile(ctx context.Context, file coremodel.MessageFile) (string, error) {
+	system, err := messageSystemRouter.system(file.Platform)
+	if err != nil {
+		return "", err
+	}
+	return system.DownloadFile(ctx, file)
+}
+
+func (messageSystemRouter *MessageSystemRouter) ReportStage(ctx context.Context, conversation coremodel.Conversation,
+	previous, stage coremodel.Stage) error {
+	system, err := messageSystemRouter.system(conversation.Platform)
+	if err != nil {
+		return err
+	}
+	return system.ReportStage(ctx, conversation, previous, stage)
+}
+
+func (messageSystemRouter *MessageSystemRouter) PublishStatus(ctx context.Context, conversation coremodel.Conversation, status coremodel.Status) (string, error) {
+	system, err := messageSystemRouter.system(conversation.Platform)
+	if err != nil {
+		return "", err
+	}
+	return system.PublishStatus(ctx, conversation, status)
+}
+
+func (messageSystemRouter *MessageSystemRouter) UpdateStatus(ctx context.Context, conversation coremodel.Conversation, statusID string, status coremodel.Status) error {
+	system, err := messageSystemRouter.system(conversation.Platform)
+	if err != nil {
+		return err
+	}
+	return system.UpdateStatus(ctx, conversation, statusID, status)
+}
+
+func (messageSystemRouter *MessageSystemRouter) PublishEphemeral(ctx context.Context, conversation coremodel.Conversation, text string) error {
+	system, err := messageSystemRouter.system(conversation.Platform)
+	if err != nil {
+		return err
+	}
+	return system.PublishEphemeral(ctx, conversation, text)
+}
+
+func (messageSystemRouter *MessageSystemRouter) RequestApproval(ctx context.Context, conversation coremodel.Conversation, request coremodel.ApprovalRequest) (string, error) {
+	system, err := messageSystemRouter.system(conversation.Platform)
+	if err != nil {
+		return "", err
+	}
+	return system.RequestApproval(ctx, conversation, request)
+}
+
+func (messageSystemRouter *MessageSystemRouter) CloseApproval(ctx context.Context, conversation coremodel.Conversation, approvalID string, result coremodel.ApprovalResult) error {
+	system, err := messageSystemRouter.system(conversation.Platform)
+	if err != nil {
+		return err
+	}
+	return system.CloseApproval(ctx, conversation, approvalID, result)
+}
@@ -0,0 +1,85 @@
+package adapter
+
+import (
+	"errors"
+	"github.com/google/go-github/github"
+	"github.com/slack-go/slack"
+	coreservice "github.com/wallacehenriquesilva/slack-assets-bot/internal/core/service"
+	"net"
+	"net/http"
+	"time"
+)
+
+// retryable is implemented by the Slack errors of a failed HTTP status, it
+// tells whether the status was a 5xx or a 429.
+type retryable interface {
+	Retryable() bool
+}
+
+// githubError marks the GitHub errors that may succeed when tried again, the
+// rate limits with the time GitHub asked to wait, the 5xx and the network
+// timeouts. Any other error is returned as it is.
+func githubError(err error) error {
+	var rateLimitError *github.RateLimitError
+	if errors.As(err, &rateLimitError) {
+		return &coreservice.TemporaryError{
+			Err:         err,
+			RetryAfter:  time.Until(rateLimitError.Rate.Reset.Time),
+			RateLimited: true,
+		}
+	}
+
+	var abuseRateLimitError *github.AbuseRateLimitError
+	if errors.As(err, &abuseRateLimitError) {
+		temporaryError := &coreservice.TemporaryError{Err: err, RateLimited: true}
+		if abuseRateLimitError.RetryAfter != nil {
+			temporaryError.RetryAfter = *abuseRateLimitError.RetryAfter
+		}
+		return temporaryError
+	}
+
+	var errorResponse *github.ErrorResponse
+	if errors.As(err, &errorResponse) && errorResponse.Response != nil {
+		code := errorResponse.Response.StatusCode
+		if code == http.StatusTooManyRequests {
+			return &coreservice.TemporaryError{Err: err, RateLimited: true}
+		}
+		if code >= http.StatusInternalServerError {
+			return &coreservice.TemporaryError{Err: err}
+		}
+		return err
+	}
+
+	return networkError(err)
+}
+
+// slackError marks the Slack errors that may succeed when tried again, the
+// rate_limited answers with the time Slack asked to wait, the 5xx and the
+// network timeouts. Any other error is returned as it is.
+func slackError(err error) error {
+	var rateLimitedError *slack.RateLimitedError
+	if errors.As(err, &rateLimitedError) {
+		return &coreservice.TemporaryError{
+			Err:         err,
+			RetryAfter:  rateLimitedError.RetryAfter,
+			RateLimited: true,
+		}
+	}
+
+	var retryableError retryable
+	if errors.As(err, &retryableError) && retryableError.Retryable() {
+		return &coreservice.TemporaryError{Err: err}
+	}
+
+	return networkError(err)
+}
+
+// networkError marks the timeouts, the request may or may not have reached
+// the other side, so only the idempotent calls are tried again.
+func networkError(err error) error {
+	var netError net.Error
+	if errors.As(err, &netError) && netError.Timeout() {
+		return &coreservice.TemporaryError{Err: err}
+	}
+	return err
+}
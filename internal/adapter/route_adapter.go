@@ -0,0 +1,311 @@
+package adapter
+
+import (
+	"fmt"
+	coremodel "github.com/wallacehenriquesilva/slack-assets-bot/internal/core/model"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/core/port/out"
+	coreservice "github.com/wallacehenriquesilva/slack-assets-bot/internal/core/service"
+	extmodel "github.com/wallacehenriquesilva/slack-assets-bot/internal/model"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	InvalidRouteError = fmt.Errorf("invalid route")
+	NoRouteError      = fmt.Errorf("no route is configured for the channel")
+	UnknownRouteError = fmt.Errorf("the route is not configured")
+)
+
+type VCSFactory func(owner, repository string) out.VersionControlSystem
+
+// Router picks the routes of an upload and keeps one VCS client per
+// repository.
+type Router struct {
+	entries    []routeEntry
+	vcsFactory VCSFactory
+	mutex      sync.Mutex
+	clients    map[string]out.VersionControlSystem
+}
+
+// routeEntry is a configured route, holding one route per target repository
+// when the route fans out. keyword matches the keyword as a whole word.
+type routeEntry struct {
+	channel string
+	keyword *regexp.Regexp
+	routes  []coremodel.Route
+}
+
+func NewRouter(routes []extmodel.RouteConfig, vcsFactory VCSFactory) (*Router, error) {
+	router := &Router{
+		entries:    make([]routeEntry, 0, len(routes)),
+		vcsFactory: vcsFactory,
+		clients:    map[string]out.VersionControlSystem{},
+	}
+
+	names := map[string]bool{}
+	for index, config := range routes {
+		entry, err := toRouteEntry(config)
+		if err != nil {
+			return nil, fmt.Errorf("%w: route %d (%s): %v", InvalidRouteError, index, config.Name, err)
+		}
+
+		for _, route := range entry.routes {
+			if names[route.Name] {
+				return nil, fmt.Errorf("%w: route %d: the name %q is used by another route, set a name to tell them apart",
+					InvalidRouteError, index, route.Name)
+			}
+			names[route.Name] = true
+		}
+		router.entries = append(router.entries, entry)
+	}
+
+	return router, nil
+}
+
+// Route returns the targets of a message. Routes with a keyword found in the
+// text as a whole word win over the routes of the same channel without a
+// keyword.
+func (router *Router) Route(channel, text string) ([]coreservice.Target, error) {
+	var fallback *routeEntry
+
+	for index := range router.entries {
+		entry := &router.entries[index]
+		if entry.channel != channel {
+			continue
+		}
+
+		if entry.keyword == nil {
+			if fallback == nil {
+				fallback = entry
+			}
+			continue
+		}
+
+		if entry.keyword.MatchString(text) {
+			return router.targets(*entry), nil
+		}
+	}
+
+	if fallback == nil {
+		return nil, NoRouteError
+	}
+
+	return router.targets(*fallback), nil
+}
+
+// Channels returns the channels with at least one route.
+func (router *Router) Channels() []string {
+	channels := make([]string, 0, len(router.entries))
+	for _, entry := range router.entries {
+		channels = append(channels, entry.channel)
+	}
+	return channels
+}
+
+// Routes returns every configured route, one per target repository.
+func (router *Router) Routes() []coremodel.Route {
+	var routes []coremodel.Route
+	for _, entry := range router.entries {
+		routes = append(routes, entry.routes...)
+	}
+	return routes
+}
+
+// AllTargets returns the targets of every configured route.
+func (router *Router) AllTargets() []coreservice.Target {
+	var targets []coreservice.Target
+	for _, entry := range router.entries {
+		targets = append(targets, router.targets(entry)...)
+	}
+	return targets
+}
+
+// Targets returns the targets of the routes with the given names.
+func (router *Router) Targets(names []string) ([]coreservice.Target, error) {
+	targets := make([]coreservice.Target, 0, len(names))
+	for _, name := range names {
+		route, ok := router.routeByName(name)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", UnknownRouteError, name)
+		}
+		targets = append(targets, router.target(route))
+	}
+	return targets, nil
+}
+
+func (router *Router) routeByName(name string) (coremodel.Route, bool) {
+	for _, route := range router.Routes() {
+		if route.Name == name {
+			return route, true
+		}
+	}
+	return coremodel.Route{}, false
+}
+
+func (router *Router) targets(entry routeEntry) []coreservice.Target {
+	targets := make([]coreservice.Target, 0, len(entry.routes))
+	for _, route := range entry.routes {
+		targets = append(targets, router.target(route))
+	}
+	return targets
+}
+
+func (router *Router) target(route coremodel.Route) coreservice.Target {
+	key := route.Owner + "/" + route.Repository
+
+	router.mutex.Lock()
+	defer router.mutex.Unlock()
+
+	client, ok := router.clients[key]
+	if !ok {
+		client = router.vcsFactory(route.Owner, route.Repository)
+		router.clients[key] = client
+	}
+
+	return coreservice.Target{
+		Route:     route,
+		VCSClient: client,
+	}
+}
+
+func toRouteEntry(config extmodel.RouteConfig) (routeEntry, error) {
+	entry := routeEntry{
+		channel: config.Channel,
+	}
+
+	if config.Channel == "" {
+		return entry, fmt.Errorf("the channel is required")
+	}
+
+	if config.Keyword != "" {
+		entry.keyword = keywordPattern(config.Keyword)
+	}
+
+	if len(config.Targets) == 0 {
+		route, err := toRoute(config)
+		if err != nil {
+			return entry, err
+		}
+		entry.routes = append(entry.routes, route)
+		return entry, nil
+	}
+
+	for _, target := range config.Targets {
+		route, err := toRoute(inheritRoute(config, target))
+		if err != nil {
+			return entry, fmt.Errorf("target %s: %v", target.Repository, err)
+		}
+		entry.routes = append(entry.routes, route)
+	}
+
+	return entry, nil
+}
+
+// keywordPattern matches the keyword, ignoring the case, when it isn't part of
+// a longer word, so "ios" doesn't match "scenarios".
+func keywordPattern(keyword string) *regexp.Regexp {
+	return regexp.MustCompile(`(?i)(?:^|[^\pL\pN_])` + regexp.QuoteMeta(keyword) + `(?:[^\pL\pN_]|$)`)
+}
+
+// inheritRoute fills the settings a fan out target doesn't set with the ones
+// of its route.
+func inheritRoute(route, target extmodel.RouteConfig) extmodel.RouteConfig {
+	target.Channel = route.Channel
+	target.Keyword = route.Keyword
+	target.Targets = nil
+
+	if target.Name == "" && route.Name != "" && target.Repository != "" {
+		target.Name = route.Name + "/" + target.Repository
+	}
+	if target.Owner == "" {
+		target.Owner = route.Owner
+	}
+	if target.BaseBranch == "" {
+		target.BaseBranch = route.BaseBranch
+	}
+	if target.TargetPath == "" {
+		target.TargetPath = route.TargetPath
+	}
+	if target.PathRules == nil {
+		target.PathRules = route.PathRules
+	}
+	if target.Transforms == nil {
+		target.Transforms = route.Transforms
+	}
+	if target.Reviewers == nil {
+		target.Reviewers = route.Reviewers
+	}
+	if target.AutoMerge == (extmodel.AutoMergeSettings{}) {
+		target.AutoMerge = route.AutoMerge
+	}
+
+	return target
+}
+
+func toRoute(config extmodel.RouteConfig) (coremodel.Route, error) {
+	route := coremodel.Route{
+		Name:       config.Name,
+		Channel:    config.Channel,
+		Keyword:    config.Keyword,
+		Owner:      config.Owner,
+		Repository: config.Repository,
+		BaseBranch: config.BaseBranch,
+		TargetPath: strings.Trim(config.TargetPath, "/"),
+		Transforms: config.Transforms,
+		Reviewers:  config.Reviewers,
+	}
+
+	if route.Channel == "" || route.Owner == "" || route.Repository == "" {
+		return route, fmt.Errorf("the channel, owner and repository are required")
+	}
+
+	if route.BaseBranch == "" {
+		route.BaseBranch = "main"
+	}
+
+	if route.Name == "" {
+		route.Name = route.Repository
+	}
+
+	for _, rule := range config.PathRules {
+		if _, err := path.Match(rule.Match, ""); err != nil {
+			return route, fmt.Errorf("invalid path rule %q: %v", rule.Match, err)
+		}
+		route.PathRules = append(route.PathRules, coremodel.PathRule{
+			Pattern:    rule.Match,
+			TargetPath: strings.Trim(rule.Target, "/"),
+		})
+	}
+
+	if err := coreservice.ValidateTransforms(route.Transforms); err != nil {
+		return route, err
+	}
+
+	autoMerge, err := toAutoMergeConfig(config.AutoMerge)
+	if err != nil {
+		return route, err
+	}
+	route.AutoMerge = autoMerge
+
+	return route, nil
+}
+
+func toAutoMergeConfig(settings extmodel.AutoMergeSettings) (coremodel.AutoMergeConfig, error) {
+	var durations [3]time.Duration
+	for index, value := range []string{settings.PollInterval, settings.Timeout, settings.ChecksGracePeriod} {
+		if value == "" {
+			continue
+		}
+
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return coremodel.AutoMergeConfig{}, err
+		}
+		durations[index] = duration
+	}
+
+	return coreservice.NewAutoMergeConfig(settings.Mode, settings.Method, durations[0], durations[1], durations[2])
+}
@@ -1,12 +1,36 @@
 package adapter
 
 import (
+	"context"
+	"fmt"
 	coremodel "github.com/wallacehenriquesilva/slack-assets-bot/internal/core/model"
 	"github.com/wallacehenriquesilva/slack-assets-bot/internal/core/port/in"
 	extmodel "github.com/wallacehenriquesilva/slack-assets-bot/internal/model"
 	"github.com/wallacehenriquesilva/slack-assets-bot/internal/service"
+	"strings"
+	"time"
 )
 
+var stageReactions = map[coremodel.Stage]string{
+	coremodel.StageReceived:         "eyes",
+	coremodel.StageDownloading:      "inbox_tray",
+	coremodel.StageExtracting:       "package",
+	coremodel.StageAwaitingApproval: "raised_hand",
+	coremodel.StageCommitting:       "hammer_and_wrench",
+	coremodel.StagePROpened:         "white_check_mark",
+	coremodel.StageFailed:           "x",
+}
+
+var stageNames = map[coremodel.Stage]string{
+	coremodel.StageReceived:         "Received",
+	coremodel.StageDownloading:      "Downloading",
+	coremodel.StageExtracting:       "Extracting",
+	coremodel.StageAwaitingApproval: "Awaiting approval",
+	coremodel.StageCommitting:       "Committing",
+	coremodel.StagePROpened:         "Pull request opened",
+	coremodel.StageFailed:           "Failed",
+}
+
 type SlackAdapter struct {
 	slackService service.SlackClient
 }
@@ -17,20 +41,189 @@ func NewSlackAdapter(slackService service.SlackClient) in.MessageSystem {
 	}
 }
 
-func (slackAdapter *SlackAdapter) PublishMessage(message coremodel.Message) error {
+func (slackAdapter *SlackAdapter) PublishMessage(ctx context.Context, message coremodel.Message) error {
 	var messageColor extmodel.SlackMessageColor
 	if message.Style == coremodel.SuccessMessage {
 		messageColor = extmodel.Success
 	} else {
 		messageColor = extmodel.Error
 	}
-	return slackAdapter.slackService.PublishMessage(message.Title, message.Message, messageColor)
+	text := message.Message
+	if message.Mention && message.Conversation.User != "" {
+		text = fmt.Sprintf("<@%s> %s", message.Conversation.User, text)
+	}
+
+	return slackError(slackAdapter.slackService.PublishRichMessage(ctx, richMessage(message, text, messageColor)))
+}
+
+// richMessage renders a message and its details, when there are any, with the pull requests as
+// buttons, the files as a table, the image previews and a context line.
+func richMessage(message coremodel.Message, text string, color extmodel.SlackMessageColor) extmodel.SlackRichMessage {
+	richMessage := extmodel.SlackRichMessage{
+		ChannelID: message.Conversation.Channel,
+		ThreadTS:  message.Conversation.Thread,
+		Pretext:   message.Title,
+		Text:      text,
+		Color:     color,
+	}
+
+	details := message.Details
+	if details == nil {
+		return richMessage
+	}
+
+	for _, link := range details.Links {
+		richMessage.Buttons = append(richMessage.Buttons, extmodel.SlackButton{Text: link.Label, Url: link.Url})
+	}
+
+	richMessage.Files = fileLines(details.Files)
+
+	for _, preview := range details.Previews {
+		richMessage.Images = append(richMessage.Images, extmodel.SlackImage{Title: preview.Name, Url: preview.Url})
+	}
+
+	var context []string
+	if details.Uploader != "" {
+		context = append(context, "Uploaded by *"+details.Uploader+"*")
+	}
+	if details.Duration > 0 {
+		context = append(context, "Processed in "+details.Duration.Round(100*time.Millisecond).String())
+	}
+	if len(context) > 0 {
+		richMessage.Context = []string{strings.Join(context, " • ")}
+	}
+
+	return richMessage
 }
 
-func (slackAdapter *SlackAdapter) DownloadFile(file coremodel.MessageFile) (string, error) {
+// fileLines prefixes the paths with their repository when the files go to
+// more than one.
+func fileLines(files []coremodel.MessageFileEntry) []extmodel.SlackFileLine {
+	multipleRepositories := hasMultipleRepositories(files)
+
+	lines := make([]extmodel.SlackFileLine, 0, len(files))
+	for _, file := range files {
+		filePath := file.Path
+		if multipleRepositories {
+			filePath = file.Repository + ": " + filePath
+		}
+		lines = append(lines, extmodel.SlackFileLine{Path: filePath, Size: file.Size})
+	}
+	return lines
+}
+
+func hasMultipleRepositories(files []coremodel.MessageFileEntry) bool {
+	for _, file := range files {
+		if file.Repository != files[0].Repository {
+			return true
+		}
+	}
+	return false
+}
+
+func (slackAdapter *SlackAdapter) DownloadFile(ctx context.Context, file coremodel.MessageFile) (string, error) {
 	slackFile := extmodel.SlackFile{
 		Url:      file.Url,
 		FileType: file.Extension,
+		Size:     int(file.Size),
 	}
-	return slackAdapter.slackService.DownloadFile(slackFile)
+	path, err := slackAdapter.slackService.DownloadFile(ctx, slackFile, file.MaxSize)
+	return path, slackError(err)
+}
+
+// ReportStage replaces the reaction of the previous stage with the one of the
+// stage, so the upload only shows where the job is.
+func (slackAdapter *SlackAdapter) ReportStage(ctx context.Context, conversation coremodel.Conversation, previous, stage coremodel.Stage) error {
+	reaction, ok := stageReactions[stage]
+	if !ok || conversation.MessageID == "" {
+		return nil
+	}
+
+	if previousReaction, ok := stageReactions[previous]; ok && previousReaction != reaction {
+		if err := slackAdapter.slackService.RemoveReaction(ctx, conversation.Channel, conversation.MessageID, previousReaction); err != nil {
+			return err
+		}
+	}
+	return slackAdapter.slackService.AddReaction(ctx, conversation.Channel, conversation.MessageID, reaction)
+}
+
+func (slackAdapter *SlackAdapter) PublishStatus(ctx context.Context, conversation coremodel.Conversation, status coremodel.Status) (string, error) {
+	return slackAdapter.slackService.PostText(ctx, conversation.Channel, conversation.Thread, statusText(status))
+}
+
+func (slackAdapter *SlackAdapter) UpdateStatus(ctx context.Context, conversation coremodel.Conversation, statusID string, status coremodel.Status) error {
+	return slackAdapter.slackService.UpdateText(ctx, conversation.Channel, statusID, statusText(status))
+}
+
+// statusIcons are the icons of the status lines, written the way each chat
+// renders them.
+type statusIcons struct {
+	done    string
+	running string
+	failed  string
+}
+
+var slackStatusIcons = statusIcons{done: ":white_check_mark:", running: ":hourglass_flowing_sand:", failed: ":x:"}
+
+// statusText renders one line per stage with the time it took, the stage in
+// progress with an hourglass and the error of a failed job.
+func statusText(status coremodel.Status) string {
+	return formatStatus(status, slackStatusIcons)
+}
+
+func formatStatus(status coremodel.Status, icons statusIcons) string {
+	lines := make([]string, 0, len(status.Stages)+2)
+	if status.JobID != "" {
+		lines = append(lines, fmt.Sprintf("Upload `%s`", status.JobID))
+	}
+
+	for _, stage := range status.Stages {
+		name := stageNames[stage.Stage]
+		switch {
+		case stage.Stage == coremodel.StageFailed:
+			lines = append(lines, fmt.Sprintf("%s %s: %s", icons.failed, name, status.Error))
+		case !stage.Done:
+			lines = append(lines, fmt.Sprintf("%s %s...", icons.running, name))
+		case stage.Duration > 0:
+			lines = append(lines, fmt.Sprintf("%s %s (%s)", icons.done, name, stage.Duration.Round(10*time.Millisecond)))
+		default:
+			lines = append(lines, fmt.Sprintf("%s %s", icons.done, name))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (slackAdapter *SlackAdapter) PublishEphemeral(ctx context.Context, conversation coremodel.Conversation, text string) error {
+	return slackAdapter.slackService.PostEphemeral(ctx, conversation.Channel, conversation.Thread, conversation.User, text)
+}
+
+func (slackAdapter *SlackAdapter) RequestApproval(ctx context.Context, conversation coremodel.Conversation, request coremodel.ApprovalRequest) (string, error) {
+	approval := extmodel.SlackApproval{
+		ChannelID: conversation.Channel,
+		ThreadTS:  conversation.Thread,
+		JobID:     request.JobID,
+		Files:     fileLines(request.Files),
+		Warnings:  request.Warnings,
+		ExpiresAt: request.ExpiresAt,
+	}
+	if conversation.User != "" {
+		approval.Mention = fmt.Sprintf("<@%s>", conversation.User)
+	}
+
+	return slackAdapter.slackService.PostApproval(ctx, approval)
+}
+
+func (slackAdapter *SlackAdapter) CloseApproval(ctx context.Context, conversation coremodel.Conversation, approvalID string, result coremodel.ApprovalResult) error {
+	var text string
+	switch result.Outcome {
+	case coremodel.ApprovalApproved:
+		text = fmt.Sprintf(":white_check_mark: Approved by <@%s>", result.User)
+	case coremodel.ApprovalCancelled:
+		text = fmt.Sprintf(":no_entry_sign: Cancelled by <@%s>", result.User)
+	default:
+		text = ":hourglass: The approval expired"
+	}
+
+	return slackAdapter.slackService.CloseApproval(ctx, conversation.Channel, approvalID, text)
 }
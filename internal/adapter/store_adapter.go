@@ -0,0 +1,221 @@
+package adapter
+
+import (
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/core/model"
+	coreservice "github.com/wallacehenriquesilva/slack-assets-bot/internal/core/service"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/service"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	pullRequestThreadsBucket = "pull_request_threads"
+	jobsBucket               = "jobs"
+	deliveriesBucket         = "deliveries"
+)
+
+// The expired deliveries are removed on a claim, and the jobs past their
+// retention on a save, once in this interval.
+const (
+	deliveryPurgeInterval = time.Hour
+	jobPurgeInterval      = time.Hour
+)
+
+type delivery struct {
+	JobID     string
+	ExpiresAt time.Time
+}
+
+// StoreAdapter keeps the finished jobs for jobRetention after their last
+// update, so the list of the jobs doesn't grow without bound. A zero
+// retention keeps them forever.
+type StoreAdapter struct {
+	store         service.KeyValueStore
+	jobRetention  time.Duration
+	deliveryMutex sync.Mutex
+	lastPurge     time.Time
+	jobMutex      sync.Mutex
+	lastJobPurge  time.Time
+}
+
+func NewStoreAdapter(store service.KeyValueStore, jobRetention time.Duration) *StoreAdapter {
+	return &StoreAdapter{
+		store:        store,
+		jobRetention: jobRetention,
+	}
+}
+
+func (storeAdapter *StoreAdapter) SavePullRequestThread(repository, branch string, thread model.PullRequestThread) error {
+	return storeAdapter.store.Put(pullRequestThreadsBucket, branchKey(repository, branch), thread)
+}
+
+// FindPullRequestThread also looks for the threads saved before the
+// repositories were lowercased, under the repository as it was written.
+func (storeAdapter *StoreAdapter) FindPullRequestThread(repository, branch string) (model.PullRequestThread, bool, error) {
+	var thread model.PullRequestThread
+	found, err := storeAdapter.store.Get(pullRequestThreadsBucket, branchKey(repository, branch), &thread)
+	if err != nil || found {
+		return thread, found, err
+	}
+
+	found, err = storeAdapter.store.Get(pullRequestThreadsBucket, repository+":"+branch, &thread)
+	return thread, found, err
+}
+
+func (storeAdapter *StoreAdapter) SaveJob(job model.Job) error {
+	if err := storeAdapter.store.Put(jobsBucket, job.ID, job); err != nil {
+		return err
+	}
+
+	storeAdapter.jobMutex.Lock()
+	defer storeAdapter.jobMutex.Unlock()
+
+	now := time.Now()
+	if storeAdapter.jobRetention > 0 && now.Sub(storeAdapter.lastJobPurge) >= jobPurgeInterval {
+		storeAdapter.lastJobPurge = now
+		storeAdapter.purgeJobs(now.Add(-storeAdapter.jobRetention))
+	}
+	return nil
+}
+
+// purgeJobs deletes the finished jobs not updated since the cutoff. The jobs
+// still running or waiting to be resumed are kept whatever their age.
+func (storeAdapter *StoreAdapter) purgeJobs(cutoff time.Time) {
+	keys, err := storeAdapter.store.Keys(jobsBucket)
+	if err != nil {
+		log.Printf("error to list the jobs: %v\n", err)
+		return
+	}
+
+	purged := 0
+	for _, key := range keys {
+		job, found, err := storeAdapter.FindJob(key)
+		if err != nil || !found || coreservice.JobUnfinished(job) || !job.UpdatedAt.Before(cutoff) {
+			continue
+		}
+		if err = storeAdapter.store.Delete(jobsBucket, key); err != nil {
+			log.Printf("error to delete the job %s: %v\n", key, err)
+			continue
+		}
+		purged++
+	}
+
+	if purged > 0 {
+		log.Printf("deleted %d finished jobs older than %s\n", purged, storeAdapter.jobRetention)
+	}
+}
+
+func (storeAdapter *StoreAdapter) FindJob(id string) (model.Job, bool, error) {
+	var job model.Job
+	found, err := storeAdapter.store.Get(jobsBucket, id, &job)
+	return job, found, err
+}
+
+// ListJobs returns the most recent jobs first.
+func (storeAdapter *StoreAdapter) ListJobs(limit int) ([]model.Job, error) {
+	keys, err := storeAdapter.store.Keys(jobsBucket)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]model.Job, 0, len(keys))
+	for _, key := range keys {
+		job, found, err := storeAdapter.FindJob(key)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			jobs = append(jobs, job)
+		}
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.After(jobs[j].CreatedAt)
+	})
+	if limit > 0 && len(jobs) > limit {
+		jobs = jobs[:limit]
+	}
+
+	return jobs, nil
+}
+
+// ClaimDelivery checks and records the keys under a lock, so two deliveries
+// of the same upload arriving together can't both be claimed.
+func (storeAdapter *StoreAdapter) ClaimDelivery(keys []string, jobID string, ttl time.Duration) (string, bool, error) {
+	storeAdapter.deliveryMutex.Lock()
+	defer storeAdapter.deliveryMutex.Unlock()
+
+	now := time.Now()
+	for _, key := range keys {
+		var claimed delivery
+		found, err := storeAdapter.store.Get(deliveriesBucket, key, &claimed)
+		if err != nil {
+			return "", false, err
+		}
+		if found && now.Before(claimed.ExpiresAt) {
+			return claimed.JobID, false, nil
+		}
+	}
+
+	for _, key := range keys {
+		if err := storeAdapter.store.Put(deliveriesBucket, key, delivery{JobID: jobID, ExpiresAt: now.Add(ttl)}); err != nil {
+			return "", false, err
+		}
+	}
+
+	if now.Sub(storeAdapter.lastPurge) >= deliveryPurgeInterval {
+		storeAdapter.lastPurge = now
+		storeAdapter.purgeDeliveries(now)
+	}
+
+	return jobID, true, nil
+}
+
+func (storeAdapter *StoreAdapter) ReleaseDelivery(keys []string, jobID string) error {
+	storeAdapter.deliveryMutex.Lock()
+	defer storeAdapter.deliveryMutex.Unlock()
+
+	for _, key := range keys {
+		var claimed delivery
+		found, err := storeAdapter.store.Get(deliveriesBucket, key, &claimed)
+		if err != nil {
+			return err
+		}
+		if !found || claimed.JobID != jobID {
+			continue
+		}
+		if err = storeAdapter.store.Delete(deliveriesBucket, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (storeAdapter *StoreAdapter) purgeDeliveries(now time.Time) {
+	keys, err := storeAdapter.store.Keys(deliveriesBucket)
+	if err != nil {
+		log.Printf("error to list the deliveries: %v\n", err)
+		return
+	}
+
+	for _, key := range keys {
+		var claimed delivery
+		found, err := storeAdapter.store.Get(deliveriesBucket, key, &claimed)
+		if err != nil || !found || now.Before(claimed.ExpiresAt) {
+			continue
+		}
+		if err = storeAdapter.store.Delete(deliveriesBucket, key); err != nil {
+			log.Printf("error to delete the delivery %s: %v\n", key, err)
+		}
+	}
+}
+
+// branchKey lowercases the repository, GitHub names the owners and the
+// repositories without regard to case, so the webhook may not write them as
+// the routes do.
+func branchKey(repository, branch string) string {
+	return strings.ToLower(repository) + ":" + branch
+}
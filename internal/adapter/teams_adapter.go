@@ -0,0 +1,187 @@
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	coremodel "github.com/wallacehenriquesilva/slack-assets-bot/internal/core/model"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/core/port/in"
+	extmodel "github.com/wallacehenriquesilva/slack-assets-bot/internal/model"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/service"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/util/fileutil"
+	"strings"
+	"time"
+)
+
+const (
+	adaptiveCardSchema  = "http://adaptivecards.io/schemas/adaptive-card.json"
+	adaptiveCardVersion = "1.4"
+	maxAdaptiveFacts    = 20
+)
+
+// TeamsAdapter replies in the thread of the upload with Adaptive Cards. Teams
+// has no reactions for bots, so the stages are only shown by the status.
+type TeamsAdapter struct {
+	teamsService service.TeamsClient
+}
+
+func NewTeamsAdapter(teamsService service.TeamsClient) in.MessageSystem {
+	return &TeamsAdapter{
+		teamsService: teamsService,
+	}
+}
+
+// PublishMessage replies with a card, the files as facts, the image previews
+// and the pull requests as buttons.
+func (teamsAdapter *TeamsAdapter) PublishMessage(ctx context.Context, message coremodel.Message) error {
+	color := "attention"
+	if message.Style == coremodel.SuccessMessage {
+		color = "good"
+	}
+
+	body := []extmodel.AdaptiveElement{
+		{Type: "TextBlock", Text: message.Title, Weight: "bolder", Size: "medium", Color: color, Wrap: true},
+		{Type: "TextBlock", Text: message.Message, Wrap: true},
+	}
+	var actions []extmodel.AdaptiveAction
+
+	if details := message.Details; details != nil {
+		if len(details.Files) > 0 {
+			body = append(body, adaptiveFiles(details.Files))
+		}
+
+		for _, preview := range details.Previews {
+			body = append(body, extmodel.AdaptiveElement{Type: "Image", Url: preview.Url, AltText: preview.Name})
+		}
+
+		var footer []string
+		if details.Uploader != "" {
+			footer = append(footer, "Uploaded by "+details.Uploader)
+		}
+		if details.Duration > 0 {
+			footer = append(footer, "Processed in "+details.Duration.Round(100*time.Millisecond).String())
+		}
+		if len(footer) > 0 {
+			body = append(body, extmodel.AdaptiveElement{Type: "TextBlock", Text: strings.Join(footer, " • "), IsSubtle: true, Wrap: true})
+		}
+
+		for _, link := range details.Links {
+			actions = append(actions, extmodel.AdaptiveAction{Type: "Action.OpenUrl", Title: link.Label, Url: link.Url})
+		}
+	}
+
+	_, err := teamsAdapter.teamsService.SendActivity(ctx, message.Conversation.ServiceURL, message.Conversation.Channel, cardActivity(message.Conversation, body, actions))
+	return err
+}
+
+func (teamsAdapter *TeamsAdapter) DownloadFile(ctx context.Context, file coremodel.MessageFile) (string, error) {
+	return teamsAdapter.teamsService.DownloadFile(ctx, file.Url, file.Extension, file.Size, file.MaxSize)
+}
+
+func (teamsAdapter *TeamsAdapter) ReportStage(ctx context.Context, conversation coremodel.Conversation, previous, stage coremodel.Stage) error {
+	return nil
+}
+
+func (teamsAdapter *TeamsAdapter) PublishStatus(ctx context.Context, conversation coremodel.Conversation, status coremodel.Status) (string, error) {
+	return teamsAdapter.teamsService.SendActivity(ctx, conversation.ServiceURL, conversation.Channel, textActivity(conversation, teamsStatusText(status)))
+}
+
+func (teamsAdapter *TeamsAdapter) UpdateStatus(ctx context.Context, conversation coremodel.Conversation, statusID string, status coremodel.Status) error {
+	return teamsAdapter.teamsService.UpdateActivity(ctx, conversation.ServiceURL, conversation.Channel, statusID, textActivity(conversation, teamsStatusText(status)))
+}
+
+// PublishEphemeral replies in the thread, the bots can't send a message only
+// one user sees.
+func (teamsAdapter *TeamsAdapter) PublishEphemeral(ctx context.Context, conversation coremodel.Conversation, text string) error {
+	_, err := teamsAdapter.teamsService.SendActivity(ctx, conversation.ServiceURL, conversation.Channel, textActivity(conversation, text))
+	return err
+}
+
+func (teamsAdapter *TeamsAdapter) RequestApproval(ctx context.Context, conversation coremodel.Conversation, request coremodel.ApprovalRequest) (string, error) {
+	body := []extmodel.AdaptiveElement{
+		{Type: "TextBlock", Text: "Review the upload before the pull request is opened", Weight: "bolder", Wrap: true},
+		{Type: "TextBlock", Text: "It expires at " + request.ExpiresAt.Format(time.Kitchen) + ".", IsSubtle: true, Wrap: true},
+	}
+	if len(request.Files) > 0 {
+		body = append(body, adaptiveFiles(request.Files))
+	}
+	for _, warning := range request.Warnings {
+		body = append(body, extmodel.AdaptiveElement{Type: "TextBlock", Text: "⚠️ " + warning, Color: "warning", Wrap: true})
+	}
+
+	actions := []extmodel.AdaptiveAction{
+		{
+			Type:  "Action.Submit",
+			Title: "Approve",
+			Style: "positive",
+			Data:  extmodel.TeamsCardAction{Action: extmodel.ApproveActionID, JobID: request.JobID},
+		},
+		{
+			Type:  "Action.Submit",
+			Title: "Cancel",
+			Style: "destructive",
+			Data:  extmodel.TeamsCardAction{Action: extmodel.CancelActionID, JobID: request.JobID},
+		},
+	}
+
+	return teamsAdapter.teamsService.SendActivity(ctx, conversation.ServiceURL, conversation.Channel, cardActivity(conversation, body, actions))
+}
+
+// CloseApproval replaces the approval card with its outcome, removing the
+// buttons.
+func (teamsAdapter *TeamsAdapter) CloseApproval(ctx context.Context, conversation coremodel.Conversation, approvalID string, result coremodel.ApprovalResult) error {
+	var text string
+	switch result.Outcome {
+	case coremodel.ApprovalApproved:
+		text = "✅ Approved"
+	case coremodel.ApprovalCancelled:
+		text = "🚫 Cancelled"
+	default:
+		text = "⌛ The approval expired"
+	}
+
+	return teamsAdapter.teamsService.UpdateActivity(ctx, conversation.ServiceURL, conversation.Channel, approvalID, textActivity(conversation, text))
+}
+
+// teamsStatusText separates the status lines with blank lines, Teams joins
+// the lines of a markdown paragraph.
+func teamsStatusText(status coremodel.Status) string {
+	return strings.ReplaceAll(formatStatus(status, unicodeStatusIcons), "\n", "\n\n")
+}
+
+func textActivity(conversation coremodel.Conversation, text string) extmodel.TeamsActivity {
+	return extmodel.TeamsActivity{
+		Type:       extmodel.TeamsMessageActivity,
+		Text:       text,
+		TextFormat: "markdown",
+		ReplyToID:  conversation.Thread,
+	}
+}
+
+func cardActivity(conversation coremodel.Conversation, body []extmodel.AdaptiveElement, actions []extmodel.AdaptiveAction) extmodel.TeamsActivity {
+	card, _ := json.Marshal(extmodel.AdaptiveCard{
+		Type:    "AdaptiveCard",
+		Schema:  adaptiveCardSchema,
+		Version: adaptiveCardVersion,
+		Body:    body,
+		Actions: actions,
+	})
+
+	return extmodel.TeamsActivity{
+		Type:        extmodel.TeamsMessageActivity,
+		ReplyToID:   conversation.Thread,
+		Attachments: []extmodel.TeamsAttachment{{ContentType: extmodel.TeamsAdaptiveCardContent, Content: card}},
+	}
+}
+
+func adaptiveFiles(files []coremodel.MessageFileEntry) extmodel.AdaptiveElement {
+	var facts []extmodel.AdaptiveFact
+	for index, line := range fileLines(files) {
+		if index == maxAdaptiveFacts {
+			facts = append(facts, extmodel.AdaptiveFact{Title: "...", Value: fmt.Sprintf("and %d more", len(files)-maxAdaptiveFacts)})
+			break
+		}
+		facts = append(facts, extmodel.AdaptiveFact{Title: line.Path, Value: fileutil.HumanSize(line.Size)})
+	}
+	return extmodel.AdaptiveElement{Type: "FactSet", Facts: facts}
+}
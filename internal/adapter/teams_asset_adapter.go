@@ -0,0 +1,141 @@
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	coremodel "github.com/wallacehenriquesilva/slack-assets-bot/internal/core/model"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/core/port/in"
+	coreservice "github.com/wallacehenriquesilva/slack-assets-bot/internal/core/service"
+	extmodel "github.com/wallacehenriquesilva/slack-assets-bot/internal/model"
+	"html"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	teamsMentionRegex = regexp.MustCompile(`(?s)<at>.*?</at>`)
+	htmlTagRegex      = regexp.MustCompile(`<[^>]*>`)
+)
+
+// TeamsAssetAdapter turns the files sent to the bot in Teams into upload
+// requests and the approval buttons into decisions.
+type TeamsAssetAdapter struct {
+	intake       in.AssetIntake
+	assetService coreservice.AssetSetvice
+}
+
+func NewTeamsAssetAdapter(intake in.AssetIntake, assetService coreservice.AssetSetvice) *TeamsAssetAdapter {
+	return &TeamsAssetAdapter{
+		intake:       intake,
+		assetService: assetService,
+	}
+}
+
+func (teamsAssetAdapter *TeamsAssetAdapter) ProcessActivity(ctx context.Context, activity extmodel.TeamsActivity) error {
+	if activity.Type != extmodel.TeamsMessageActivity || activity.From == nil || activity.Conversation == nil {
+		return nil
+	}
+
+	if len(activity.Value) > 0 {
+		return teamsAssetAdapter.processAction(ctx, activity)
+	}
+
+	attachments := teamsFiles(activity.Attachments)
+	if len(attachments) == 0 {
+		return nil
+	}
+
+	conversation := coremodel.Conversation{
+		Platform:   coremodel.PlatformTeams,
+		Channel:    activity.Conversation.ID,
+		Thread:     activity.ID,
+		MessageID:  activity.ID,
+		User:       activity.From.ID,
+		ServiceURL: activity.ServiceUrl,
+	}
+
+	files := make([]coremodel.UploadFile, 0, len(attachments))
+	for _, attachment := range attachments {
+		url, extension, err := teamsDownload(attachment)
+		if err != nil {
+			_ = teamsAssetAdapter.assetService.SendErrorMessage(ctx, conversation, err)
+			return err
+		}
+		files = append(files, coremodel.UploadFile{Url: url, Extension: extension, Name: attachment.Name})
+	}
+
+	// The conversation of a channel thread is the channel followed by the
+	// message the thread started from, the routes only know the channel.
+	return teamsAssetAdapter.intake.Submit(ctx, coremodel.AssetUploadRequest{
+		DeliveryID:   activity.ID,
+		Requester:    coremodel.Requester{ID: activity.From.ID, Name: activity.From.Name},
+		Conversation: conversation,
+		Channel:      strings.SplitN(activity.Conversation.ID, ";messageid=", 2)[0],
+		Files:        files,
+		Text:         teamsText(activity.Text),
+		Timestamp:    time.Now(),
+	})
+}
+
+// processAction handles the approval buttons, whose data is sent back as the
+// value of a message replying to the card.
+func (teamsAssetAdapter *TeamsAssetAdapter) processAction(ctx context.Context, activity extmodel.TeamsActivity) error {
+	var action extmodel.TeamsCardAction
+	if err := json.Unmarshal(activity.Value, &action); err != nil {
+		return nil
+	}
+	if action.Action != extmodel.ApproveActionID && action.Action != extmodel.CancelActionID {
+		return nil
+	}
+
+	return teamsAssetAdapter.assetService.Decide(ctx, coremodel.ApprovalDecision{
+		JobID:    action.JobID,
+		Approved: action.Action == extmodel.ApproveActionID,
+		Conversation: coremodel.Conversation{
+			Platform:   coremodel.PlatformTeams,
+			Channel:    activity.Conversation.ID,
+			Thread:     activity.ReplyToID,
+			User:       activity.From.ID,
+			ServiceURL: activity.ServiceUrl,
+		},
+	})
+}
+
+// teamsFiles keeps the attachments that are files, leaving out the HTML
+// copy of the message Teams attaches to it.
+func teamsFiles(attachments []extmodel.TeamsAttachment) []extmodel.TeamsAttachment {
+	var files []extmodel.TeamsAttachment
+	for _, attachment := range attachments {
+		if attachment.ContentType == extmodel.TeamsFileDownloadInfoContent ||
+			(attachment.ContentUrl != "" && attachment.ContentType != "text/html") {
+			files = append(files, attachment)
+		}
+	}
+	return files
+}
+
+func teamsDownload(attachment extmodel.TeamsAttachment) (string, string, error) {
+	extension := strings.ToLower(strings.TrimPrefix(path.Ext(attachment.Name), "."))
+	if attachment.ContentType != extmodel.TeamsFileDownloadInfoContent {
+		return attachment.ContentUrl, extension, nil
+	}
+
+	var info extmodel.TeamsFileDownloadInfo
+	if err := json.Unmarshal(attachment.Content, &info); err != nil {
+		return "", "", err
+	}
+	if info.FileType != "" {
+		extension = strings.ToLower(info.FileType)
+	}
+	return info.DownloadUrl, extension, nil
+}
+
+// teamsText removes the mentions of the bot and the HTML of the message.
+func teamsText(text string) string {
+	text = teamsMentionRegex.ReplaceAllString(text, "")
+	text = strings.NewReplacer("<br>", "\n", "<br/>", "\n", "</p>", "\n").Replace(text)
+	text = htmlTagRegex.ReplaceAllString(text, "")
+	return strings.TrimSpace(html.UnescapeString(text))
+}
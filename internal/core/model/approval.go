@@ -0,0 +1,39 @@
+package model
+
+import "time"
+
+type ApprovalOutcome string
+
+const (
+	ApprovalApproved  ApprovalOutcome = "approved"
+	ApprovalCancelled ApprovalOutcome = "cancelled"
+	ApprovalExpired   ApprovalOutcome = "expired"
+)
+
+type (
+	// ApprovalConfig holds the approvers of each chat by their user IDs in
+	// that chat.
+	ApprovalConfig struct {
+		Enabled   bool
+		Approvers map[Platform][]string
+		Timeout   time.Duration
+	}
+
+	ApprovalRequest struct {
+		JobID     string
+		Files     []MessageFileEntry
+		Warnings  []string
+		ExpiresAt time.Time
+	}
+
+	ApprovalDecision struct {
+		JobID        string
+		Approved     bool
+		Conversation Conversation
+	}
+
+	ApprovalResult struct {
+		Outcome ApprovalOutcome
+		User    string
+	}
+)
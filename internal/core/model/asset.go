@@ -1,6 +1,34 @@
 package model
 
-type AssetFile struct {
-	Url       string
-	Extension string
-}
+import "time"
+
+type (
+	AssetDirectives struct {
+		TargetPath string
+		Reviewers  []string
+		BaseBranch string
+	}
+
+	AssetMessage struct {
+		Title       string
+		Description string
+		Directives  AssetDirectives
+	}
+
+	// AssetFile is an upload. FileID and DeliveryID are the ids the chat
+	// gave to the file and to the event it came in, used to recognize a
+	// repeated delivery.
+	AssetFile struct {
+		FileID       string
+		DeliveryID   string
+		Url          string
+		Extension    string
+		Name         string
+		Size         int64
+		Uploader     string
+		Text         string
+		Message      AssetMessage
+		Conversation Conversation
+		Timestamp    time.Time
+	}
+)
@@ -0,0 +1,54 @@
+package model
+
+import "time"
+
+// JobState is the last step of a job that was saved. The jobs the bot was
+// running when it stopped are resumed from their state on startup, the
+// interrupted ones were saved by the bot on its way down.
+type JobState string
+
+const (
+	JobStateReceived         JobState = "received"
+	JobStateDownloaded       JobState = "downloaded"
+	JobStateExtracted        JobState = "extracted"
+	JobStateAwaitingApproval JobState = "awaiting_approval"
+	JobStateCommitted        JobState = "committed"
+	JobStatePROpened         JobState = "pr_opened"
+	JobStateNotified         JobState = "notified"
+	JobStateFailed           JobState = "failed"
+	JobStateInterrupted      JobState = "interrupted"
+)
+
+type (
+	// JobTarget is the progress of a job in one of its routes, kept so a
+	// resumed job reuses its branch and skips the steps already done. Key
+	// names the repository, base branch and target path of the route, which
+	// find the progress again even when the route was renamed. Created tells
+	// the branch was created by the job, so it is deleted when the job fails
+	// before opening its pull request.
+	JobTarget struct {
+		Key         string
+		Route       string
+		Branch      string
+		Created     bool
+		Committed   bool
+		PullRequest *PullRequest
+	}
+
+	// Job is the record of an upload, kept to answer the status and history
+	// commands, to retry the uploads that failed and to resume the ones that
+	// were interrupted. Attempts counts the calls made in each step, more than
+	// one means the step was retried.
+	Job struct {
+		ID           string
+		AssetFile    AssetFile
+		Routes       []string
+		State        JobState
+		Targets      []JobTarget
+		Attempts     map[string]int
+		Status       Status
+		PullRequests []PullRequest
+		CreatedAt    time.Time
+		UpdatedAt    time.Time
+	}
+)
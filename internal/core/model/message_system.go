@@ -1,5 +1,7 @@
 package model
 
+import "time"
+
 type MessageStyle string
 
 var (
@@ -7,15 +9,75 @@ var (
 	SuccessMessage MessageStyle = "success"
 )
 
+// Platform is the chat a conversation happens on.
+type Platform string
+
+const (
+	PlatformSlack      Platform = "slack"
+	PlatformDiscord    Platform = "discord"
+	PlatformMattermost Platform = "mattermost"
+	PlatformTeams      Platform = "teams"
+)
+
 type (
+	// MessageFile is the upload to download. Size is the size the chat
+	// announced, MaxSize the largest file accepted, zero when unknown or
+	// without limit.
 	MessageFile struct {
+		Platform  Platform
 		Url       string
 		Extension string
+		Size      int64
+		MaxSize   int64
+	}
+
+	// Conversation is where the answers of an upload go. The platform picks
+	// the message system that writes them. ServiceURL is the endpoint the
+	// chat answers the conversation on, kept with the jobs for the chats that
+	// give it with each message, as Teams does.
+	Conversation struct {
+		Platform   Platform
+		Channel    string
+		Thread     string
+		MessageID  string
+		User       string
+		ServiceURL string
+	}
+
+	MessageLink struct {
+		Label string
+		Url   string
+	}
+
+	MessageFileEntry struct {
+		Repository string
+		Path       string
+		Size       int64
+	}
+
+	MessagePreview struct {
+		Name string
+		Url  string
+	}
+
+	// MessageDetails is the structured content of a message, each message
+	// system renders it the best way it can.
+	MessageDetails struct {
+		Links    []MessageLink
+		Files    []MessageFileEntry
+		Previews []MessagePreview
+		Uploader string
+		Duration time.Duration
 	}
 
+	// Message is posted in the conversation, mentioning its user when
+	// Mention is set, which is only done for the results and the failures.
 	Message struct {
-		Title   string
-		Message string
-		Style   MessageStyle
+		Title        string
+		Message      string
+		Style        MessageStyle
+		Conversation Conversation
+		Details      *MessageDetails
+		Mention      bool
 	}
 )
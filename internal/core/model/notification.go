@@ -0,0 +1,26 @@
+package model
+
+type PullRequestEventKind string
+
+const (
+	PullRequestApproved         PullRequestEventKind = "approved"
+	PullRequestChangesRequested PullRequestEventKind = "changes_requested"
+	PullRequestMerged           PullRequestEventKind = "merged"
+	PullRequestClosed           PullRequestEventKind = "closed"
+	PullRequestChecksFailed     PullRequestEventKind = "checks_failed"
+)
+
+type (
+	PullRequestEvent struct {
+		Kind       PullRequestEventKind
+		Repository string
+		Branch     string
+		Url        string
+		Actor      string
+	}
+
+	PullRequestThread struct {
+		Url          string
+		Conversation Conversation
+	}
+)
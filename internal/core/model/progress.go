@@ -0,0 +1,38 @@
+package model
+
+import "time"
+
+type Stage string
+
+const (
+	StageReceived         Stage = "received"
+	StageDownloading      Stage = "downloading"
+	StageExtracting       Stage = "extracting"
+	StageAwaitingApproval Stage = "awaiting_approval"
+	StageCommitting       Stage = "committing"
+	StagePROpened         Stage = "pr_opened"
+	StageFailed           Stage = "failed"
+)
+
+type (
+	StageTiming struct {
+		Stage    Stage
+		Duration time.Duration
+		Done     bool
+	}
+
+	Status struct {
+		JobID  string
+		Stages []StageTiming
+		Error  string
+	}
+
+	// StageTimeouts bound each call made in a stage, a zero timeout leaves
+	// the call bounded only by the job.
+	StageTimeouts struct {
+		Download    time.Duration
+		Commit      time.Duration
+		PullRequest time.Duration
+		Message     time.Duration
+	}
+)
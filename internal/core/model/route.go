@@ -0,0 +1,22 @@
+package model
+
+type (
+	PathRule struct {
+		Pattern    string
+		TargetPath string
+	}
+
+	Route struct {
+		Name       string
+		Channel    string
+		Keyword    string
+		Owner      string
+		Repository string
+		BaseBranch string
+		TargetPath string
+		PathRules  []PathRule
+		Transforms []string
+		Reviewers  []string
+		AutoMerge  AutoMergeConfig
+	}
+)
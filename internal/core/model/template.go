@@ -0,0 +1,36 @@
+package model
+
+import "time"
+
+type (
+	TemplateFile struct {
+		Name string
+		Path string
+		Size int64
+	}
+
+	TemplateData struct {
+		Uploader    string
+		Text        string
+		Title       string
+		Description string
+		Route       string
+		Repository  string
+		TargetPath  string
+		BaseBranch  string
+		Reviewers   []string
+		Channel     string
+		Timestamp   time.Time
+		Upload      TemplateFile
+		Files       []TemplateFile
+		FileCount   int
+		TotalSize   int64
+	}
+
+	RenderedTemplates struct {
+		Branch        string
+		CommitMessage string
+		PRTitle       string
+		PRDescription string
+	}
+)
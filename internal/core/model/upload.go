@@ -0,0 +1,35 @@
+package model
+
+import "time"
+
+type (
+	Requester struct {
+		ID   string
+		Name string
+	}
+
+	UploadFile struct {
+		ID        string
+		Url       string
+		Extension string
+		Name      string
+		Size      int64
+	}
+
+	// AssetUploadRequest is an upload received from any chat or transport.
+	// DeliveryID is the id of the event or message the upload came in,
+	// Channel is what the upload is routed by, the channel of the
+	// conversation when empty, Route the name of the route the requester
+	// picked, as in the upload form, instead, and Text is the message without
+	// the markup of the chat.
+	AssetUploadRequest struct {
+		DeliveryID   string
+		Requester    Requester
+		Conversation Conversation
+		Channel      string
+		Route        string
+		Files        []UploadFile
+		Text         string
+		Timestamp    time.Time
+	}
+)
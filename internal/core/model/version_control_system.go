@@ -1,6 +1,58 @@
 package model
 
-type VCSFile struct {
-	LocalPath  string
-	RemotePath string
-}
+import "time"
+
+type (
+	MergeMethod   string
+	AutoMergeMode string
+	ChecksState   string
+)
+
+const (
+	MergeMethodMerge  MergeMethod = "merge"
+	MergeMethodSquash MergeMethod = "squash"
+	MergeMethodRebase MergeMethod = "rebase"
+
+	AutoMergeDisabled AutoMergeMode = ""
+	AutoMergeGithub   AutoMergeMode = "github"
+	AutoMergePoll     AutoMergeMode = "poll"
+
+	ChecksPending ChecksState = "pending"
+	ChecksSuccess ChecksState = "success"
+	ChecksFailure ChecksState = "failure"
+)
+
+type (
+	VCSFile struct {
+		LocalPath  string
+		RemotePath string
+	}
+
+	PullRequest struct {
+		Repository string
+		Number     int
+		Url        string
+		NodeID     string
+		HeadBranch string
+		HeadSHA    string
+	}
+
+	Branch struct {
+		Name        string
+		CommittedAt time.Time
+	}
+
+	ChecksStatus struct {
+		State  ChecksState
+		Total  int
+		Failed []string
+	}
+
+	AutoMergeConfig struct {
+		Mode              AutoMergeMode
+		Method            MergeMethod
+		PollInterval      time.Duration
+		Timeout           time.Duration
+		ChecksGracePeriod time.Duration
+	}
+)
@@ -0,0 +1,12 @@
+package in
+
+import (
+	"context"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/core/model"
+)
+
+// AssetIntake receives the uploads, whatever chat or transport they come
+// from.
+type AssetIntake interface {
+	Submit(ctx context.Context, request model.AssetUploadRequest) error
+}
@@ -1,8 +1,17 @@
 package in
 
-import "github.com/wallacehenriquesilva/slack-assets-bot/internal/core/model"
+import (
+	"context"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/core/model"
+)
 
 type MessageSystem interface {
-	PublishMessage(message model.Message) error
-	DownloadFile(file model.MessageFile) (string, error)
+	PublishMessage(ctx context.Context, message model.Message) error
+	DownloadFile(ctx context.Context, file model.MessageFile) (string, error)
+	ReportStage(ctx context.Context, conversation model.Conversation, previous, stage model.Stage) error
+	PublishStatus(ctx context.Context, conversation model.Conversation, status model.Status) (string, error)
+	UpdateStatus(ctx context.Context, conversation model.Conversation, statusID string, status model.Status) error
+	PublishEphemeral(ctx context.Context, conversation model.Conversation, text string) error
+	RequestApproval(ctx context.Context, conversation model.Conversation, request model.ApprovalRequest) (string, error)
+	CloseApproval(ctx context.Context, conversation model.Conversation, approvalID string, result model.ApprovalResult) error
 }
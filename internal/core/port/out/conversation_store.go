@@ -0,0 +1,8 @@
+package out
+
+import "github.com/wallacehenriquesilva/slack-assets-bot/internal/core/model"
+
+type ConversationStore interface {
+	SavePullRequestThread(repository, branch string, thread model.PullRequestThread) error
+	FindPullRequestThread(repository, branch string) (model.PullRequestThread, bool, error)
+}
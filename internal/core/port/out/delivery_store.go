@@ -0,0 +1,13 @@
+package out
+
+import "time"
+
+type DeliveryStore interface {
+	// ClaimDelivery records the keys of a delivery for the job until the ttl
+	// expires. When one of the keys is already recorded nothing is claimed and
+	// the job it belongs to is returned.
+	ClaimDelivery(keys []string, jobID string, ttl time.Duration) (string, bool, error)
+	// ReleaseDelivery drops the keys the job still holds, the keys claimed
+	// by another job are kept.
+	ReleaseDelivery(keys []string, jobID string) error
+}
@@ -0,0 +1,9 @@
+package out
+
+import "github.com/wallacehenriquesilva/slack-assets-bot/internal/core/model"
+
+type JobStore interface {
+	SaveJob(job model.Job) error
+	FindJob(id string) (model.Job, bool, error)
+	ListJobs(limit int) ([]model.Job, error)
+}
@@ -1,8 +1,22 @@
 package out
 
-import "github.com/wallacehenriquesilva/slack-assets-bot/internal/core/model"
+import (
+	"context"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/core/model"
+)
 
 type VersionControlSystem interface {
-	CreateCommit(commitBranch, baseBranch, message string, sourceFiles []model.VCSFile) error
-	CreatePullRequest(headBranch, baseBranch, title, description string) (string, error)
+	// CreateCommit commits the files to the commit branch, creating it from
+	// the base branch. An existing branch is only committed to when
+	// reuseBranch is set, otherwise it fails with BranchExistsError.
+	CreateCommit(ctx context.Context, commitBranch, baseBranch string, reuseBranch bool, message string,
+		sourceFiles []model.VCSFile) (bool, error)
+	CreatePullRequest(ctx context.Context, headBranch, baseBranch, title, description string, reviewers []string) (model.PullRequest, error)
+	FindPullRequest(ctx context.Context, headBranch string) (model.PullRequest, bool, error)
+	ListBranches(ctx context.Context, prefix string) ([]model.Branch, error)
+	DeleteBranch(ctx context.Context, branch string) error
+	EnableAutoMerge(ctx context.Context, pullRequest model.PullRequest, method model.MergeMethod) error
+	GetChecksStatus(ctx context.Context, pullRequest model.PullRequest) (model.ChecksStatus, error)
+	MergePullRequest(ctx context.Context, pullRequest model.PullRequest, method model.MergeMethod) error
+	FileURL(ctx context.Context, pullRequest model.PullRequest, remotePath string) (string, error)
 }
@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/core/model"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/util/fileutil"
+	"log"
+	"path"
+	"strings"
+	"time"
+)
+
+var (
+	ApprovalExpiredError      = fmt.Errorf("the upload was not approved in time")
+	ApprovalCancelledError    = fmt.Errorf("the upload was cancelled")
+	UnknownJobError           = fmt.Errorf("the upload is no longer waiting for an approval")
+	UnauthorizedApproverError = fmt.Errorf("you are not allowed to approve this upload")
+)
+
+const largeFileSize = 1 << 20
+
+// NewApprovalConfig reads the approvers, each written as platform:userID.
+// The approvers without a known platform are Slack user IDs, as they were
+// before the other chats were added.
+func NewApprovalConfig(enabled bool, approvers []string, timeout time.Duration) model.ApprovalConfig {
+	config := model.ApprovalConfig{
+		Enabled:   enabled,
+		Approvers: map[model.Platform][]string{},
+		Timeout:   timeout,
+	}
+
+	for _, approver := range approvers {
+		platform, user := model.PlatformSlack, approver
+		if parts := strings.SplitN(approver, ":", 2); len(parts) == 2 {
+			switch model.Platform(parts[0]) {
+			case model.PlatformSlack, model.PlatformDiscord, model.PlatformMattermost, model.PlatformTeams:
+				platform, user = model.Platform(parts[0]), parts[1]
+			}
+		}
+		config.Approvers[platform] = append(config.Approvers[platform], user)
+	}
+
+	return config
+}
+
+// pendingJob is a job waiting for a decision, removed when it is decided or
+// when its timer expires.
+type pendingJob struct {
+	job        *assetJob
+	approvalID string
+	timer      *time.Timer
+}
+
+// requestApproval posts the summary of the job and keeps the extracted files
+// until an approver decides or the approval expires.
+func (assetService *AssetSetviceImpl) requestApproval(ctx context.Context, job *assetJob) error {
+	id := job.id
+	request := assetService.approvalRequest(job)
+	job.progress.record.State = model.JobStateAwaitingApproval
+	job.progress.stage(ctx, model.StageAwaitingApproval)
+
+	approvalID, err := assetService.requestApprovalMessage(ctx, job.assetFile.Conversation, request)
+	if err != nil {
+		job.cleanup()
+		return assetService.fail(ctx, job.progress, job.assetFile.Conversation, err)
+	}
+
+	assetService.pendingMutex.Lock()
+	assetService.pendingJobs[id] = &pendingJob{
+		job:        job,
+		approvalID: approvalID,
+		timer:      time.AfterFunc(assetService.approval.Timeout, func() { assetService.expire(id) }),
+	}
+	assetService.pendingMutex.Unlock()
+
+	return nil
+}
+
+func (assetService *AssetSetviceImpl) requestApprovalMessage(ctx context.Context, conversation model.Conversation,
+	request model.ApprovalRequest) (string, error) {
+	ctx, cancel := withTimeout(ctx, assetService.timeouts.Message)
+	defer cancel()
+
+	return assetService.messageClient.RequestApproval(ctx, conversation, request)
+}
+
+// Decide approves or cancels a pending job. Only the configured approvers of
+// the chat, or the uploader when the chat has none, can decide. An approved
+// job goes back to the queue, the context only bounds the answer to the
+// approver.
+func (assetService *AssetSetviceImpl) Decide(ctx context.Context, decision model.ApprovalDecision) error {
+	user := decision.Conversation.User
+
+	assetService.pendingMutex.Lock()
+	pending, ok := assetService.pendingJobs[decision.JobID]
+	authorized := ok && assetService.canDecide(pending.job, decision.Conversation)
+	if authorized {
+		delete(assetService.pendingJobs, decision.JobID)
+		pending.timer.Stop()
+	}
+	assetService.pendingMutex.Unlock()
+
+	if !ok {
+		return assetService.rejectDecision(ctx, decision, UnknownJobError)
+	}
+	if !authorized {
+		return assetService.rejectDecision(ctx, decision, UnauthorizedApproverError)
+	}
+
+	result := model.ApprovalResult{Outcome: model.ApprovalCancelled, User: user}
+	if decision.Approved {
+		result.Outcome = model.ApprovalApproved
+	}
+
+	assetService.closeApproval(ctx, pending, result)
+
+	if !decision.Approved {
+		pending.job.cleanup()
+		pending.job.progress.fail(ctx, ApprovalCancelledError)
+		assetService.releaseDelivery(pending.job.progress.record)
+		return nil
+	}
+
+	return assetService.enqueue(ctx, pending.job, func(ctx context.Context, job *assetJob) error {
+		defer job.cleanup()
+		return assetService.publishJob(ctx, job)
+	})
+}
+
+// expire runs on the background context of the queue, the request that
+// started the job is long gone.
+func (assetService *AssetSetviceImpl) expire(id string) {
+	assetService.pendingMutex.Lock()
+	pending, ok := assetService.pendingJobs[id]
+	delete(assetService.pendingJobs, id)
+	assetService.pendingMutex.Unlock()
+
+	if !ok {
+		return
+	}
+
+	ctx := assetService.queue.background()
+	result := model.ApprovalResult{Outcome: model.ApprovalExpired}
+	assetService.closeApproval(ctx, pending, result)
+
+	pending.job.cleanup()
+	_ = assetService.fail(ctx, pending.job.progress, pending.job.assetFile.Conversation, ApprovalExpiredError)
+}
+
+// closeApproval replaces the buttons of the approval with its outcome, a
+// failure is only logged.
+func (assetService *AssetSetviceImpl) closeApproval(ctx context.Context, pending *pendingJob, result model.ApprovalResult) {
+	ctx, cancel := withTimeout(ctx, assetService.timeouts.Message)
+	defer cancel()
+
+	err := assetService.messageClient.CloseApproval(ctx, pending.job.assetFile.Conversation, pending.approvalID, result)
+	if err != nil {
+		log.Printf("error to close the approval of %s: %v\n", pending.job.id, err)
+	}
+}
+
+func (assetService *AssetSetviceImpl) canDecide(job *assetJob, conversation model.Conversation) bool {
+	if len(assetService.approvers(conversation)) == 0 {
+		return conversation.User == job.assetFile.Conversation.User
+	}
+	return assetService.isApprover(conversation)
+}
+
+// isApprover tells whether the user of the conversation is one of the
+// approvers configured for its chat.
+func (assetService *AssetSetviceImpl) isApprover(conversation model.Conversation) bool {
+	for _, approver := range assetService.approvers(conversation) {
+		if approver == conversation.User {
+			return true
+		}
+	}
+	return false
+}
+
+// approvers returns the approvers of the chat of the conversation, the
+// conversations without a platform are Slack ones.
+func (assetService *AssetSetviceImpl) approvers(conversation model.Conversation) []string {
+	platform := conversation.Platform
+	if platform == "" {
+		platform = model.PlatformSlack
+	}
+	return assetService.approval.Approvers[platform]
+}
+
+// rejectDecision tells only the user who clicked why nothing happened.
+func (assetService *AssetSetviceImpl) rejectDecision(ctx context.Context, decision model.ApprovalDecision, er error) error {
+	ctx, cancel := withTimeout(ctx, assetService.timeouts.Message)
+	defer cancel()
+
+	if err := assetService.messageClient.PublishEphemeral(ctx, decision.Conversation, er.Error()); err != nil {
+		log.Printf("error to reply to %s: %v\n", decision.Conversation.User, err)
+	}
+	return er
+}
+
+// approvalRequest summarizes where each file goes and warns about what is
+// usually a mistake in the upload.
+func (assetService *AssetSetviceImpl) approvalRequest(job *assetJob) model.ApprovalRequest {
+	request := model.ApprovalRequest{
+		JobID:     job.id,
+		ExpiresAt: time.Now().Add(assetService.approval.Timeout),
+	}
+
+	if len(job.unzipedFiles) == 0 {
+		request.Warnings = append(request.Warnings, "The zip has no files to commit")
+	}
+
+	for _, target := range job.targets {
+		repository := target.Route.Owner + "/" + target.Route.Repository
+		files := unzipedToVcs(job.unzipedFiles, target.Route, job.assetFile.Message.Directives.TargetPath)
+
+		for _, file := range files {
+			size := fileSize(file.LocalPath)
+			request.Files = append(request.Files, model.MessageFileEntry{
+				Repository: repository,
+				Path:       file.RemotePath,
+				Size:       size,
+			})
+
+			if size > largeFileSize {
+				request.Warnings = append(request.Warnings, fmt.Sprintf("%s is %s", file.RemotePath, fileutil.HumanSize(size)))
+			}
+
+			if strings.Contains(path.Base(file.RemotePath), " ") {
+				request.Warnings = append(request.Warnings, fmt.Sprintf("%s has spaces in its name", file.RemotePath))
+			}
+		}
+	}
+
+	return request
+}
@@ -1,105 +1,471 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"github.com/gofrs/uuid"
 	"github.com/wallacehenriquesilva/slack-assets-bot/internal/core/model"
 	"github.com/wallacehenriquesilva/slack-assets-bot/internal/core/port/in"
 	"github.com/wallacehenriquesilva/slack-assets-bot/internal/core/port/out"
 	"github.com/wallacehenriquesilva/slack-assets-bot/internal/util/fileutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 )
 
 type AssetSetvice interface {
-	Process(assetFile model.AssetFile) error
-	SendErrorMessage(er error) error
+	Process(ctx context.Context, assetFile model.AssetFile, targets []Target) error
+	Decide(ctx context.Context, decision model.ApprovalDecision) error
+	FindJob(id string) (model.Job, bool, error)
+	ListJobs(limit int) ([]model.Job, error)
+	Retry(ctx context.Context, requester model.Conversation, id string, targets []Target) error
+	Cancel(ctx context.Context, requester model.Conversation, id string) error
+	UnfinishedJobs() ([]model.Job, error)
+	Resume(ctx context.Context, job model.Job, targets []Target) error
+	SendErrorMessage(ctx context.Context, conversation model.Conversation, er error) error
+	Stop(grace time.Duration)
+}
+
+// Target is the route of an upload together with the client of the
+// repository it points to.
+type Target struct {
+	Route     model.Route
+	VCSClient out.VersionControlSystem
+}
+
+// assetJob is an upload that was downloaded and extracted and is ready to be
+// published.
+type assetJob struct {
+	id           string
+	assetFile    model.AssetFile
+	targets      []Target
+	file         string
+	unzipFolder  string
+	unzipedFiles []fileutil.File
+	progress     *progress
+	resumed      bool
+	approved     bool
+}
+
+func (job *assetJob) cleanup() {
+	_ = fileutil.DeleteFiles(job.file)
+	if job.unzipFolder != "" {
+		_ = fileutil.DeleteFiles(job.unzipFolder)
+	}
+}
+
+type targetResult struct {
+	target      Target
+	pullRequest model.PullRequest
+	files       []model.VCSFile
+	err         error
 }
 
 var (
 	InvalidURLError           = fmt.Errorf("invalid url to file. The url is required")
 	InvalidFileExtensionError = fmt.Errorf("invalid file format. The format allowed is only zip")
+	InvalidTargetPathError    = fmt.Errorf("invalid target path. The path must be relative to the repository root")
+	NoTargetError             = fmt.Errorf("no target repository is configured for the upload")
+	PartialFailureError       = fmt.Errorf("the asset was not published to every target")
+	DuplicateRemotePathError  = fmt.Errorf("more than one file of the upload goes to the same path")
+	BranchExistsError         = fmt.Errorf("the branch of the upload already exists in the repository")
 )
 
 type AssetSetviceImpl struct {
-	vcsClient     out.VersionControlSystem
-	messageClient in.MessageSystem
-	baseBranch    string
-	commitMessage string
-	prTitle       string
-	prDescription string
+	messageClient     in.MessageSystem
+	conversationStore out.ConversationStore
+	jobStore          out.JobStore
+	templates         *Templates
+	previewLimit      int
+	approval          model.ApprovalConfig
+	queue             *JobQueue
+	deliveryStore     out.DeliveryStore
+	deliveryTTL       time.Duration
+	retryPolicy       RetryPolicy
+	timeouts          model.StageTimeouts
+	maxFileSize       int64
+	pendingMutex      sync.Mutex
+	pendingJobs       map[string]*pendingJob
 }
 
-func NewAssetService(messageClient in.MessageSystem, vcsClient out.VersionControlSystem, baseBranch, commitMessage,
-	prTitle, prDescription string) AssetSetvice {
+func NewAssetService(messageClient in.MessageSystem, conversationStore out.ConversationStore, jobStore out.JobStore,
+	templates *Templates, previewLimit int, approval model.ApprovalConfig, queue *JobQueue,
+	deliveryStore out.DeliveryStore, deliveryTTL time.Duration, retryPolicy RetryPolicy,
+	timeouts model.StageTimeouts, maxFileSize int64) AssetSetvice {
 	return &AssetSetviceImpl{
-		messageClient: messageClient,
-		vcsClient:     vcsClient,
-		baseBranch:    baseBranch,
-		commitMessage: commitMessage,
-		prTitle:       prTitle,
-		prDescription: prDescription,
+		messageClient:     messageClient,
+		conversationStore: conversationStore,
+		jobStore:          jobStore,
+		templates:         templates,
+		previewLimit:      previewLimit,
+		approval:          approval,
+		queue:             queue,
+		deliveryStore:     deliveryStore,
+		deliveryTTL:       deliveryTTL,
+		retryPolicy:       retryPolicy,
+		timeouts:          timeouts,
+		maxFileSize:       maxFileSize,
+		pendingJobs:       map[string]*pendingJob{},
+	}
+}
+
+// Process records the upload and queues it, answering right away. The job
+// downloads and extracts the upload once and opens one pull request per
+// target. A failure in one target doesn't stop the others, every result is
+// reported in a single message. A repeated delivery of an upload only gets
+// the id of its job. The context only bounds the answer, the job runs with
+// the one of the queue.
+func (assetService *AssetSetviceImpl) Process(ctx context.Context, assetFile model.AssetFile, targets []Target) error {
+	conversation := assetFile.Conversation
+
+	id, err := newUUID()
+	if err != nil {
+		_ = assetService.SendErrorMessage(ctx, conversation, err)
+		return err
+	}
+
+	if jobID, claimed := assetService.claimDelivery(assetFile, id); !claimed {
+		return assetService.replyDuplicate(ctx, conversation, jobID)
+	}
+
+	return assetService.start(ctx, id, assetFile, targets)
+}
+
+// start records the job and queues it.
+func (assetService *AssetSetviceImpl) start(ctx context.Context, id string, assetFile model.AssetFile, targets []Target) error {
+	conversation := assetFile.Conversation
+
+	record := &model.Job{
+		ID:        id,
+		AssetFile: assetFile,
+		Routes:    routeNames(targets),
+		State:     model.JobStateReceived,
+		CreatedAt: time.Now(),
 	}
+	progress := assetService.newProgress(record)
+	progress.stage(ctx, model.StageReceived)
+
+	if err := ValidateAssetFile(assetFile); err != nil {
+		return assetService.fail(ctx, progress, conversation, err)
+	}
+
+	if len(targets) == 0 {
+		return assetService.fail(ctx, progress, conversation, NoTargetError)
+	}
+
+	job := &assetJob{
+		id:        id,
+		assetFile: assetFile,
+		targets:   targets,
+		progress:  progress,
+	}
+	return assetService.enqueue(ctx, job, assetService.process)
 }
 
-func (assetService *AssetSetviceImpl) Process(assetFile model.AssetFile) error {
-	if err := assetService.validateAssetFile(assetFile); err != nil {
-		_ = assetService.SendErrorMessage(err)
+func (assetService *AssetSetviceImpl) newProgress(record *model.Job) *progress {
+	return newProgress(assetService.messageClient, assetService.jobStore, record, assetService.timeouts.Message)
+}
+
+// enqueue runs the step of the job on the queue, failing the job when the
+// queue is full or the step panics and interrupting it when the queue stops.
+// The context is the one of the caller, used to report a job that can't be
+// queued.
+func (assetService *AssetSetviceImpl) enqueue(ctx context.Context, job *assetJob,
+	run func(ctx context.Context, job *assetJob) error) error {
+	fail := func(ctx context.Context, err error) {
+		job.cleanup()
+		_ = assetService.fail(ctx, job.progress, job.assetFile.Conversation, err)
+	}
+
+	err := assetService.queue.enqueue(task{
+		jobID: job.id,
+		run:   func(ctx context.Context) error { return run(ctx, job) },
+		fail:  fail,
+		interrupt: func() {
+			job.cleanup()
+			_ = assetService.interrupt(job.progress)
+		},
+	})
+	if err != nil {
+		fail(ctx, err)
 		return err
 	}
+	return nil
+}
+
+func (assetService *AssetSetviceImpl) process(ctx context.Context, job *assetJob) error {
+	assetFile := job.assetFile
+	conversation := assetFile.Conversation
+	progress := job.progress
 
 	messageFile := model.MessageFile{
+		Platform:  conversation.Platform,
 		Url:       assetFile.Url,
 		Extension: assetFile.Extension,
+		Size:      assetFile.Size,
+		MaxSize:   assetService.maxFileSize,
 	}
 
-	file, err := assetService.messageClient.DownloadFile(messageFile)
-	if err != nil {
-		_ = assetService.SendErrorMessage(err)
+	progress.stage(ctx, model.StageDownloading)
+	attempts, err := assetService.retryPolicy.do(ctx, assetService.timeouts.Download, true, func(ctx context.Context) (err error) {
+		job.file, err = assetService.messageClient.DownloadFile(ctx, messageFile)
 		return err
+	})
+	progress.attempts("download", attempts)
+	if err != nil {
+		return assetService.fail(ctx, progress, conversation, err)
 	}
-	defer fileutil.DeleteFiles(file)
 
-	unzipedFiles, err := fileutil.UnzipFiles(file, ignoreFile)
+	progress.transition(model.JobStateDownloaded)
+
+	progress.stage(ctx, model.StageExtracting)
+	job.unzipFolder, job.unzipedFiles, err = fileutil.UnzipFiles(job.file, ignoreFile)
+	if err == nil {
+		err = checkRemotePaths(job)
+	}
+	if err == nil {
+		err = jobError(ctx)
+	}
 	if err != nil {
-		_ = assetService.SendErrorMessage(err)
-		return err
+		job.cleanup()
+		return assetService.fail(ctx, progress, conversation, err)
 	}
-	defer deleteUnzipedFiles(unzipedFiles)
 
-	files := unzipedToVcs(unzipedFiles)
+	progress.transition(model.JobStateExtracted)
 
-	branchName, err := generateBranchName()
-	if err != nil {
-		_ = assetService.SendErrorMessage(err)
-		return err
+	if assetService.approval.Enabled && !job.approved {
+		return assetService.requestApproval(ctx, job)
 	}
 
-	err = assetService.vcsClient.CreateCommit(branchName, assetService.baseBranch, assetService.commitMessage, files)
+	defer job.cleanup()
+	return assetService.publishJob(ctx, job)
+}
+
+// publishJob opens the pull requests of an extracted upload and reports the
+// results.
+func (assetService *AssetSetviceImpl) publishJob(ctx context.Context, job *assetJob) error {
+	assetFile := job.assetFile
+	conversation := assetFile.Conversation
+	progress := job.progress
+
+	progress.stage(ctx, model.StageCommitting)
+	results := make([]targetResult, 0, len(job.targets))
+	for _, target := range job.targets {
+		if err := jobError(ctx); err != nil {
+			results = append(results, targetResult{target: target, err: err})
+			continue
+		}
+
+		pullRequest, files, err := assetService.publish(ctx, job, target)
+		if err != nil {
+			log.Printf("error to publish the asset to %s: %v\n", target.Route.Name, err)
+		}
+		results = append(results, targetResult{target: target, pullRequest: pullRequest, files: files, err: err})
+	}
+
+	progress.record.PullRequests = nil
+	for _, result := range results {
+		if result.err == nil {
+			progress.record.PullRequests = append(progress.record.PullRequests, result.pullRequest)
+		}
+	}
+
+	// The routes keep what they got done, the resumed job picks them up.
+	if assetService.interrupted(ctx, nil) {
+		return assetService.interrupt(progress)
+	}
+
+	if succeeded(results) {
+		progress.record.State = model.JobStatePROpened
+		progress.done(ctx, model.StagePROpened)
+	} else {
+		progress.fail(ctx, results[0].err)
+		assetService.releaseDelivery(progress.record)
+	}
+
+	// The pull requests are open even when the job ran out of time, so the
+	// results are always reported. Every message is already bounded by its
+	// own timeout.
+	details := assetService.resultDetails(reportContext(ctx), assetFile, results, progress.elapsed())
+	attempts, err := assetService.retryPolicy.do(reportContext(ctx), 0, false, func(ctx context.Context) error {
+		return assetService.sendResultMessage(ctx, progress.record.ID, conversation, results, details)
+	})
+	progress.attempts("result_message", attempts)
 	if err != nil {
-		_ = assetService.SendErrorMessage(err)
+		progress.save()
 		return err
 	}
+	if succeeded(results) {
+		progress.transition(model.JobStateNotified)
+	}
+
+	var failed []string
+	for _, result := range results {
+		if result.err != nil {
+			failed = append(failed, result.target.Route.Name)
+			continue
+		}
+		assetService.startAutoMerge(reportContext(ctx), conversation, result.target, result.pullRequest)
+	}
+
+	if len(failed) == len(results) {
+		return results[0].err
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%w: %s", PartialFailureError, strings.Join(failed, ", "))
+	}
 
-	prUrl, err := assetService.vcsClient.CreatePullRequest(
-		branchName,
-		assetService.baseBranch,
-		assetService.prTitle,
-		assetService.prDescription,
-	)
+	return nil
+}
+
+// publish commits the extracted files to the repository of the target and
+// opens the pull request. The steps are saved on the job, a resumed job keeps
+// its branch and skips what was already done.
+func (assetService *AssetSetviceImpl) publish(ctx context.Context, job *assetJob, target Target) (model.PullRequest, []model.VCSFile, error) {
+	assetFile := job.assetFile
+	route := target.Route
+	directives := assetFile.Message.Directives
+	files := unzipedToVcs(job.unzipedFiles, route, directives.TargetPath)
+
+	baseBranch := targetBaseBranch(route, directives)
+	state := job.progress.target(route, baseBranch)
+	if state.PullRequest != nil {
+		return *state.PullRequest, files, nil
+	}
+
+	reviewers := append(append([]string{}, route.Reviewers...), directives.Reviewers...)
+
+	rendered, err := assetService.templates.Render(newTemplateData(assetFile, route, baseBranch, reviewers, files))
 	if err != nil {
-		_ = assetService.SendErrorMessage(err)
-		return err
+		return model.PullRequest{}, files, err
+	}
+
+	// Only a resumed job reuses the branch its progress names, the branch of
+	// a new job may belong to another upload when the template renders the
+	// same name twice.
+	reuseBranch := job.resumed && state.Branch != ""
+	if state.Branch == "" {
+		state.Branch = rendered.Branch
+		job.progress.save()
+	}
+
+	// The commit goes to the branch of the job, a commit made twice only
+	// leaves an empty commit behind, but a pull request opened twice would be
+	// a duplicate.
+	if !state.Committed {
+		commit := func() error {
+			attempts, err := assetService.retryPolicy.do(ctx, assetService.timeouts.Commit, true, func(ctx context.Context) error {
+				created, err := target.VCSClient.CreateCommit(ctx, state.Branch, baseBranch, reuseBranch || state.Created,
+					rendered.CommitMessage, files)
+				state.Created = state.Created || created
+				return err
+			})
+			job.progress.attempts("commit:"+route.Name, attempts)
+			return err
+		}
+
+		err = commit()
+		if errors.Is(err, BranchExistsError) && !reuseBranch {
+			err = assetService.renameBranch(job, state, rendered.Branch)
+			if err == nil {
+				err = commit()
+			}
+		}
+		if err != nil {
+			assetService.deleteBranch(ctx, job, target, state)
+			return model.PullRequest{}, files, err
+		}
+		state.Committed = true
+		job.progress.transition(model.JobStateCommitted)
+	}
+
+	branch := state.Branch
+	pullRequest, found := model.PullRequest{}, false
+	if job.resumed {
+		_, err = assetService.retryPolicy.do(ctx, assetService.timeouts.PullRequest, true, func(ctx context.Context) (err error) {
+			pullRequest, found, err = target.VCSClient.FindPullRequest(ctx, branch)
+			return err
+		})
+		if err != nil {
+			return model.PullRequest{}, files, err
+		}
+	}
+	if !found {
+		attempts, err := assetService.retryPolicy.do(ctx, assetService.timeouts.PullRequest, false, func(ctx context.Context) (err error) {
+			pullRequest, err = target.VCSClient.CreatePullRequest(
+				ctx,
+				branch,
+				baseBranch,
+				rendered.PRTitle,
+				rendered.PRDescription,
+				reviewers,
+			)
+			return err
+		})
+		job.progress.attempts("pull_request:"+route.Name, attempts)
+		if err != nil {
+			assetService.deleteBranch(ctx, job, target, state)
+			return model.PullRequest{}, files, err
+		}
+	}
+
+	state.PullRequest = &pullRequest
+	job.progress.save()
+
+	thread := model.PullRequestThread{
+		Url:          pullRequest.Url,
+		Conversation: assetFile.Conversation,
+	}
+	if err = assetService.conversationStore.SavePullRequestThread(pullRequest.Repository, pullRequest.HeadBranch, thread); err != nil {
+		log.Printf("error to save the thread of %s: %v\n", pullRequest.Url, err)
 	}
 
-	err = assetService.sendSuccessMessage(prUrl)
+	return pullRequest, files, nil
+}
+
+// renameBranch moves the job to the rendered branch with a short unique
+// suffix, once the rendered one turned out to belong to another upload.
+func (assetService *AssetSetviceImpl) renameBranch(job *assetJob, state *model.JobTarget, rendered string) error {
+	id, err := newUUID()
 	if err != nil {
 		return err
 	}
 
+	state.Branch = rendered + "-" + id[:8]
+	job.progress.save()
+	log.Printf("the branch %s of the job %s already exists, using %s\n", rendered, job.id, state.Branch)
 	return nil
 }
 
-func (assetService *AssetSetviceImpl) validateAssetFile(assetFile model.AssetFile) error {
+// deleteBranch deletes the branch the job created for a route when its pull
+// request couldn't be opened, so no branch is left behind without a pull
+// request. A branch that already existed is kept.
+func (assetService *AssetSetviceImpl) deleteBranch(ctx context.Context, job *assetJob, target Target, state *model.JobTarget) {
+	if !state.Created {
+		return
+	}
+
+	_, err := assetService.retryPolicy.do(reportContext(ctx), assetService.timeouts.Commit, true, func(ctx context.Context) error {
+		return target.VCSClient.DeleteBranch(ctx, state.Branch)
+	})
+	if err != nil {
+		log.Printf("error to delete the branch %s of the job %s: %v\n", state.Branch, job.id, err)
+		return
+	}
+
+	state.Branch = ""
+	state.Created = false
+	state.Committed = false
+	job.progress.save()
+}
+
+// ValidateAssetFile checks the upload before anything is downloaded. It is
+// exported so the upload form can show the errors next to the fields.
+func ValidateAssetFile(assetFile model.AssetFile) error {
 	if assetFile.Url == "" {
 		return InvalidURLError
 	}
@@ -108,57 +474,256 @@ func (assetService *AssetSetviceImpl) validateAssetFile(assetFile model.AssetFil
 		return InvalidFileExtensionError
 	}
 
-	return nil
+	if targetPath := assetFile.Message.Directives.TargetPath; targetPath != "" {
+		if path.IsAbs(targetPath) || path.Clean(targetPath) != targetPath ||
+			targetPath == ".." || strings.HasPrefix(targetPath, "../") {
+			return InvalidTargetPathError
+		}
+	}
+
+	if baseBranch := assetFile.Message.Directives.BaseBranch; baseBranch != "" {
+		if err := validateBranchName(baseBranch); err != nil {
+			return err
+		}
+	}
+
+	return checkReviewers(assetFile.Message.Directives.Reviewers)
+}
+
+// fail reports an error that stops the whole job, even when the context of
+// the job is done. A job stopped by the bot going down isn't failed, it is
+// interrupted to be resumed.
+func (assetService *AssetSetviceImpl) fail(ctx context.Context, progress *progress, conversation model.Conversation, err error) error {
+	if assetService.interrupted(ctx, err) {
+		return assetService.interrupt(progress)
+	}
+	if cancelled := jobError(ctx); errors.Is(cancelled, JobCancelledError) {
+		err = cancelled
+	}
+
+	ctx = reportContext(ctx)
+	progress.fail(ctx, err)
+	assetService.releaseDelivery(progress.record)
+	_ = assetService.SendErrorMessage(ctx, conversation, err)
+	return err
 }
 
-func (assetService *AssetSetviceImpl) SendErrorMessage(er error) error {
-	err := assetService.sendMessage("Error to process the asset", er.Error(), model.ErrorMessage)
+func (assetService *AssetSetviceImpl) SendErrorMessage(ctx context.Context, conversation model.Conversation, er error) error {
+	err := assetService.sendMessage(ctx, conversation, "Error to process the asset", er.Error(), model.ErrorMessage)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (assetService *AssetSetviceImpl) sendSuccessMessage(prUrl string) error {
-	message := "You can see the PR opened in :arrow_right: " + prUrl
-	err := assetService.sendMessage("Asset processed with success", message, model.SuccessMessage)
+// sendResultMessage keeps the single target messages as they always were and
+// lists every pull request and failure when the upload fans out. An upload
+// published to some of its routes tells how to retry only the others.
+func (assetService *AssetSetviceImpl) sendResultMessage(ctx context.Context, jobID string, conversation model.Conversation,
+	results []targetResult, details *model.MessageDetails) error {
+	if len(results) == 1 {
+		if results[0].err != nil {
+			return assetService.SendErrorMessage(ctx, conversation, results[0].err)
+		}
+		return assetService.sendSuccessMessage(ctx, conversation, results[0].pullRequest.Url, details)
+	}
+
+	lines := make([]string, 0, len(results))
+	style := model.SuccessMessage
+	for _, result := range results {
+		if result.err != nil {
+			style = model.ErrorMessage
+			lines = append(lines, fmt.Sprintf(":x: *%s*: %v", result.target.Route.Name, result.err))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf(":white_check_mark: *%s*: %s", result.target.Route.Name, result.pullRequest.Url))
+	}
+
+	title := "Asset processed with success"
+	if style == model.ErrorMessage && succeeded(results) {
+		title = "Asset published to some of the routes"
+		lines = append(lines, fmt.Sprintf("Run `/assets retry %s` to publish it to the failed routes only.", jobID))
+	} else if style == model.ErrorMessage {
+		title = "Asset processed with errors"
+	}
+
+	return assetService.publishMessage(ctx, model.Message{
+		Title:        title,
+		Message:      strings.Join(lines, "\n"),
+		Style:        style,
+		Conversation: conversation,
+		Details:      details,
+		Mention:      true,
+	})
+}
+
+func (assetService *AssetSetviceImpl) sendSuccessMessage(ctx context.Context, conversation model.Conversation, prUrl string,
+	details *model.MessageDetails) error {
+	err := assetService.publishMessage(ctx, model.Message{
+		Title:        "Asset processed with success",
+		Message:      "You can see the PR opened in :arrow_right: " + prUrl,
+		Style:        model.SuccessMessage,
+		Conversation: conversation,
+		Details:      details,
+		Mention:      true,
+	})
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (assetService *AssetSetviceImpl) sendMessage(title, message string, style model.MessageStyle) error {
-	messageContent := model.Message{
-		Title:   title,
-		Message: message,
-		Style:   style,
-	}
+// sendMessage posts a message without details, the errors mention the user
+// of the conversation.
+func (assetService *AssetSetviceImpl) sendMessage(ctx context.Context, conversation model.Conversation, title, message string,
+	style model.MessageStyle) error {
+	return assetService.publishMessage(ctx, model.Message{
+		Title:        title,
+		Message:      message,
+		Style:        style,
+		Conversation: conversation,
+		Mention:      style == model.ErrorMessage,
+	})
+}
 
-	err := assetService.messageClient.PublishMessage(messageContent)
+// publishMessage bounds the message by the timeout of the messages.
+func (assetService *AssetSetviceImpl) publishMessage(ctx context.Context, message model.Message) error {
+	ctx, cancel := withTimeout(ctx, assetService.timeouts.Message)
+	defer cancel()
+
+	err := assetService.messageClient.PublishMessage(ctx, message)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func generateBranchName() (string, error) {
-	u4, err := uuid.NewV4()
+// resultDetails lists the pull requests and the committed files of the
+// successful targets, with a preview of the first images.
+func (assetService *AssetSetviceImpl) resultDetails(ctx context.Context, assetFile model.AssetFile, results []targetResult,
+	duration time.Duration) *model.MessageDetails {
+	details := &model.MessageDetails{
+		Uploader: assetFile.Uploader,
+		Duration: duration,
+	}
+
+	for _, result := range results {
+		if result.err != nil {
+			continue
+		}
+
+		label := "Open pull request"
+		if len(results) > 1 {
+			label = result.target.Route.Name
+		}
+		details.Links = append(details.Links, model.MessageLink{Label: label, Url: result.pullRequest.Url})
+
+		for _, file := range result.files {
+			details.Files = append(details.Files, model.MessageFileEntry{
+				Repository: result.pullRequest.Repository,
+				Path:       file.RemotePath,
+				Size:       fileSize(file.LocalPath),
+			})
+
+			if len(details.Previews) < assetService.previewLimit && isPreviewable(file.RemotePath) {
+				if preview, ok := assetService.preview(ctx, result, file.RemotePath); ok {
+					details.Previews = append(details.Previews, preview)
+				}
+			}
+		}
+	}
+
+	return details
+}
+
+// preview returns the image of a committed file, a file whose URL can't be
+// got is only left without a preview.
+func (assetService *AssetSetviceImpl) preview(ctx context.Context, result targetResult, remotePath string) (model.MessagePreview, bool) {
+	ctx, cancel := withTimeout(ctx, assetService.timeouts.PullRequest)
+	defer cancel()
+
+	url, err := result.target.VCSClient.FileURL(ctx, result.pullRequest, remotePath)
 	if err != nil {
-		return "", err
+		log.Printf("error to get the url of %s: %v\n", remotePath, err)
+		return model.MessagePreview{}, false
+	}
+
+	return model.MessagePreview{Name: path.Base(remotePath), Url: url}, true
+}
+
+func routeNames(targets []Target) []string {
+	names := make([]string, 0, len(targets))
+	for _, target := range targets {
+		names = append(names, target.Route.Name)
+	}
+	return names
+}
+
+// targetBaseBranch is the base branch of the directives, or the one of the
+// route.
+func targetBaseBranch(route model.Route, directives model.AssetDirectives) string {
+	if directives.BaseBranch != "" {
+		return directives.BaseBranch
+	}
+	return route.BaseBranch
+}
+
+func succeeded(results []targetResult) bool {
+	for _, result := range results {
+		if result.err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func newTemplateData(assetFile model.AssetFile, route model.Route, baseBranch string, reviewers []string,
+	files []model.VCSFile) model.TemplateData {
+	data := model.TemplateData{
+		Uploader:    assetFile.Uploader,
+		Text:        assetFile.Text,
+		Title:       assetFile.Message.Title,
+		Description: assetFile.Message.Description,
+		Route:       route.Name,
+		Repository:  route.Owner + "/" + route.Repository,
+		TargetPath:  assetFile.Message.Directives.TargetPath,
+		BaseBranch:  baseBranch,
+		Reviewers:   reviewers,
+		Channel:     assetFile.Conversation.Channel,
+		Timestamp:   assetFile.Timestamp,
+		Upload: model.TemplateFile{
+			Name: assetFile.Name,
+			Path: assetFile.Name,
+			Size: assetFile.Size,
+		},
+		Files: make([]model.TemplateFile, 0, len(files)),
+	}
+
+	for _, file := range files {
+		size := fileSize(file.LocalPath)
+
+		data.Files = append(data.Files, model.TemplateFile{
+			Name: path.Base(file.RemotePath),
+			Path: file.RemotePath,
+			Size: size,
+		})
+		data.TotalSize += size
 	}
+	data.FileCount = len(data.Files)
 
-	return "asset-" + u4.String(), nil
+	return data
 }
 
-func unzipedToVcs(unzipedFiles []fileutil.File) []model.VCSFile {
+func unzipedToVcs(unzipedFiles []fileutil.File, route model.Route, targetPath string) []model.VCSFile {
 	files := make([]model.VCSFile, 0, len(unzipedFiles)+1)
 
 	for _, file := range unzipedFiles {
+		relativePath := filepath.ToSlash(file.RemotePath)
+		folder := targetFolder(route, targetPath, relativePath)
 
 		fl := model.VCSFile{
 			LocalPath:  file.LocalPath,
-			RemotePath: file.RemotePath,
+			RemotePath: path.Join(folder, applyTransforms(relativePath, route.Transforms)),
 		}
 
 		files = append(files, fl)
@@ -167,14 +732,60 @@ func unzipedToVcs(unzipedFiles []fileutil.File) []model.VCSFile {
 	return files
 }
 
-func ignoreFile(file string) bool {
-	return strings.HasPrefix(file, "_")
+// checkRemotePaths fails the upload when two of its files end up at the same
+// path of a repository, which the transforms and the path rules can cause, as
+// one of them would overwrite the other.
+func checkRemotePaths(job *assetJob) error {
+	for _, target := range job.targets {
+		seen := map[string]bool{}
+		for _, file := range unzipedToVcs(job.unzipedFiles, target.Route, job.assetFile.Message.Directives.TargetPath) {
+			if seen[file.RemotePath] {
+				return fmt.Errorf("%w: %s in %s/%s", DuplicateRemotePathError, file.RemotePath,
+					target.Route.Owner, target.Route.Repository)
+			}
+			seen[file.RemotePath] = true
+		}
+	}
+	return nil
+}
+
+// targetFolder returns the folder of the repository where a file goes. The
+// path directive wins over the path rules of the route, which win over the
+// default path of the route.
+func targetFolder(route model.Route, targetPath, relativePath string) string {
+	if targetPath != "" {
+		return targetPath
+	}
+
+	for _, rule := range route.PathRules {
+		if matched, _ := path.Match(rule.Pattern, relativePath); matched {
+			return rule.TargetPath
+		}
+		if matched, _ := path.Match(rule.Pattern, path.Base(relativePath)); matched {
+			return rule.TargetPath
+		}
+	}
+
+	return route.TargetPath
 }
 
-func deleteUnzipedFiles(unzipedFiles []fileutil.File) {
-	if len(unzipedFiles) > 0 {
-		filePath := unzipedFiles[0].LocalPath
-		zipFolder := strings.Split(filePath, "/")[0]
-		_ = fileutil.DeleteFiles(zipFolder)
+func fileSize(localPath string) int64 {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return 0
 	}
+	return info.Size()
+}
+
+// isPreviewable tells if a file is an image the message systems can show.
+func isPreviewable(remotePath string) bool {
+	switch strings.ToLower(path.Ext(remotePath)) {
+	case ".png", ".jpg", ".jpeg", ".gif":
+		return true
+	}
+	return false
+}
+
+func ignoreFile(file string) bool {
+	return strings.HasPrefix(file, "_")
 }
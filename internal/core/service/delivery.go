@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/core/model"
+	"log"
+)
+
+// claimDelivery records the upload for the job unless the same event or file
+// was already delivered, returning the job it belongs to. When the store
+// fails the upload is processed, a duplicate is better than a lost upload.
+func (assetService *AssetSetviceImpl) claimDelivery(assetFile model.AssetFile, jobID string) (string, bool) {
+	keys := deliveryKeys(assetFile)
+	if len(keys) == 0 {
+		return jobID, true
+	}
+
+	claimedID, claimed, err := assetService.deliveryStore.ClaimDelivery(keys, jobID, assetService.deliveryTTL)
+	if err != nil {
+		log.Printf("error to claim the delivery of %s: %v\n", jobID, err)
+		return jobID, true
+	}
+	return claimedID, claimed
+}
+
+// releaseDelivery lets the file be uploaded again when its job failed before
+// committing to any route. The event stays claimed, a repeated delivery of
+// the same message is still ignored.
+func (assetService *AssetSetviceImpl) releaseDelivery(record *model.Job) {
+	for _, target := range record.Targets {
+		if target.Committed {
+			return
+		}
+	}
+
+	key := fileDeliveryKey(record.AssetFile)
+	if key == "" {
+		return
+	}
+	if err := assetService.deliveryStore.ReleaseDelivery([]string{key}, record.ID); err != nil {
+		log.Printf("error to release the delivery of %s: %v\n", record.ID, err)
+	}
+}
+
+// replyDuplicate points the uploader to the job already processing the
+// upload.
+func (assetService *AssetSetviceImpl) replyDuplicate(ctx context.Context, conversation model.Conversation, jobID string) error {
+	log.Printf("ignoring a repeated delivery of the job %s\n", jobID)
+
+	ctx, cancel := withTimeout(ctx, assetService.timeouts.Message)
+	defer cancel()
+
+	text := fmt.Sprintf("This file is already the upload `%s`, see `/assets status %s`.", jobID, jobID)
+	if err := assetService.messageClient.PublishEphemeral(ctx, conversation, text); err != nil {
+		log.Printf("error to reply to the repeated delivery of %s: %v\n", jobID, err)
+	}
+	return nil
+}
+
+// deliveryKeys are the ids of the event and of the file, per platform since
+// the chats don't share them.
+func deliveryKeys(assetFile model.AssetFile) []string {
+	var keys []string
+	if assetFile.DeliveryID != "" {
+		keys = append(keys, fmt.Sprintf("%s:event:%s", deliveryPlatform(assetFile), assetFile.DeliveryID))
+	}
+	if key := fileDeliveryKey(assetFile); key != "" {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func fileDeliveryKey(assetFile model.AssetFile) string {
+	if assetFile.FileID == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:file:%s", deliveryPlatform(assetFile), assetFile.FileID)
+}
+
+func deliveryPlatform(assetFile model.AssetFile) model.Platform {
+	if assetFile.Conversation.Platform == "" {
+		return model.PlatformSlack
+	}
+	return assetFile.Conversation.Platform
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/core/model"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/core/port/out"
+	"log"
+	"strings"
+	"time"
+)
+
+// BranchJanitor deletes the branches the bot left behind, the ones a job
+// recorded, starting with the branch prefix, without an open pull request and
+// with no commit for longer than maxAge. The branches the jobs didn't record
+// are never deleted, even with the prefix, and the branches of the jobs that
+// are still running are kept. In dry run the branches are only reported.
+type BranchJanitor struct {
+	jobStore out.JobStore
+	targets  []Target
+	routes   map[string]string
+	prefix   string
+	maxAge   time.Duration
+	interval time.Duration
+	dryRun   bool
+}
+
+// NewBranchJanitor creates a janitor for the repositories of the targets,
+// each repository is swept once even when several routes point to it.
+func NewBranchJanitor(jobStore out.JobStore, targets []Target, prefix string, maxAge, interval time.Duration,
+	dryRun bool) *BranchJanitor {
+	repositories, routes := map[string]bool{}, map[string]string{}
+	unique := make([]Target, 0, len(targets))
+	for _, target := range targets {
+		repository := target.Route.Owner + "/" + target.Route.Repository
+		routes[target.Route.Name] = repository
+		if !repositories[repository] {
+			repositories[repository] = true
+			unique = append(unique, target)
+		}
+	}
+
+	if interval <= 0 {
+		interval = 24 * time.Hour
+	}
+
+	return &BranchJanitor{
+		jobStore: jobStore,
+		targets:  unique,
+		routes:   routes,
+		prefix:   prefix,
+		maxAge:   maxAge,
+		interval: interval,
+		dryRun:   dryRun,
+	}
+}
+
+// Start sweeps the repositories right away and then every interval, until
+// the context is done.
+func (janitor *BranchJanitor) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(janitor.interval)
+		defer ticker.Stop()
+
+		for {
+			janitor.sweep(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// sweep deletes, or reports in dry run, the stale branches of every
+// repository, stopping when the context is done.
+func (janitor *BranchJanitor) sweep(ctx context.Context) {
+	recorded, running, err := janitor.jobBranches()
+	if err != nil {
+		log.Printf("error to list the jobs for the branch janitor: %v\n", err)
+		return
+	}
+
+	cutoff := time.Now().Add(-janitor.maxAge)
+	for _, target := range janitor.targets {
+		if ctx.Err() != nil {
+			return
+		}
+		repository := target.Route.Owner + "/" + target.Route.Repository
+
+		branches, err := target.VCSClient.ListBranches(ctx, janitor.prefix)
+		if err != nil {
+			log.Printf("error to list the branches of %s: %v\n", repository, err)
+			continue
+		}
+
+		stale := 0
+		for _, branch := range branches {
+			key := branchKey(repository, branch.Name)
+			if !recorded[key] || running[key] || !branch.CommittedAt.Before(cutoff) {
+				continue
+			}
+
+			_, open, err := target.VCSClient.FindPullRequest(ctx, branch.Name)
+			if err != nil {
+				log.Printf("error to find the pull request of %s in %s: %v\n", branch.Name, repository, err)
+				continue
+			}
+			if open {
+				continue
+			}
+
+			stale++
+			janitor.clean(ctx, repository, target.VCSClient, branch)
+		}
+
+		log.Printf("branch janitor: %d of the %d branches of %s starting with %q are stale uploads\n",
+			stale, len(branches), repository, janitor.prefix)
+	}
+}
+
+func (janitor *BranchJanitor) clean(ctx context.Context, repository string, vcsClient out.VersionControlSystem, branch model.Branch) {
+	lastCommit := branch.CommittedAt.Format(time.RFC3339)
+
+	if janitor.dryRun {
+		log.Printf("branch janitor: would delete %s in %s, last commit %s\n", branch.Name, repository, lastCommit)
+		return
+	}
+
+	if err := vcsClient.DeleteBranch(ctx, branch.Name); err != nil {
+		log.Printf("error to delete the branch %s in %s: %v\n", branch.Name, repository, err)
+		return
+	}
+	log.Printf("branch janitor: deleted %s in %s, last commit %s\n", branch.Name, repository, lastCommit)
+}
+
+// jobBranches returns the branches recorded by the jobs and, among them, the
+// branches of the jobs that aren't finished, a job waiting to be resumed
+// still needs its branch. Both are keyed by repository and branch, the same
+// branch name in another repository is a different branch.
+func (janitor *BranchJanitor) jobBranches() (map[string]bool, map[string]bool, error) {
+	jobs, err := janitor.jobStore.ListJobs(0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	recorded, running := map[string]bool{}, map[string]bool{}
+	for _, job := range jobs {
+		for _, target := range job.Targets {
+			repository := janitor.targetRepository(target)
+			if target.Branch == "" || repository == "" {
+				continue
+			}
+			key := branchKey(repository, target.Branch)
+			recorded[key] = true
+			if JobUnfinished(job) {
+				running[key] = true
+			}
+		}
+	}
+	return recorded, running, nil
+}
+
+// targetRepository returns the owner/repo of a job target, from its key or,
+// for the jobs saved before the key, from the route it was published to.
+func (janitor *BranchJanitor) targetRepository(target model.JobTarget) string {
+	if index := strings.Index(target.Key, ":"); index > 0 {
+		return target.Key[:index]
+	}
+	return janitor.routes[target.Route]
+}
+
+// branchKey ignores the case of the repository, GitHub does.
+func branchKey(repository, branch string) string {
+	return strings.ToLower(repository) + ":" + branch
+}
@@ -0,0 +1,219 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/core/model"
+	"log"
+	"time"
+)
+
+var (
+	JobNotFoundError    = fmt.Errorf("there is no upload with this id")
+	NotRetryableError   = fmt.Errorf("only the uploads that failed in some of their routes can be retried")
+	NotResumableError   = fmt.Errorf("only the unfinished uploads can be resumed")
+	NotRetrierError     = fmt.Errorf("only the uploader or the approvers can retry the upload")
+	NotCancellableError = fmt.Errorf("only the uploads in progress can be cancelled")
+	NotCancellerError   = fmt.Errorf("only the uploader or the approvers can cancel the upload")
+)
+
+func (assetService *AssetSetviceImpl) FindJob(id string) (model.Job, bool, error) {
+	return assetService.jobStore.FindJob(id)
+}
+
+func (assetService *AssetSetviceImpl) ListJobs(limit int) ([]model.Job, error) {
+	return assetService.jobStore.ListJobs(limit)
+}
+
+// Retry queues the upload of a failed job again as a new job, reporting in
+// the thread of the original upload. Only the routes without a pull request
+// are retried, an upload published to some of its routes goes to the others.
+// Only the uploader and the approvers can retry an upload.
+func (assetService *AssetSetviceImpl) Retry(ctx context.Context, requester model.Conversation, id string, targets []Target) error {
+	job, found, err := assetService.jobStore.FindJob(id)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return JobNotFoundError
+	}
+	if requester.User != job.AssetFile.Conversation.User && !assetService.isApprover(requester) {
+		return NotRetrierError
+	}
+	if JobUnfinished(job) {
+		return NotRetryableError
+	}
+	if targets = unpublishedTargets(job, targets); len(targets) == 0 {
+		return NotRetryableError
+	}
+
+	newID, err := newUUID()
+	if err != nil {
+		return err
+	}
+	return assetService.start(ctx, newID, job.AssetFile, targets)
+}
+
+// Cancel stops an upload waiting for its approval, waiting in the queue or in
+// progress. The routes keep what they got done before it stopped. Only the
+// uploader and the approvers can cancel an upload.
+func (assetService *AssetSetviceImpl) Cancel(ctx context.Context, requester model.Conversation, id string) error {
+	job, found, err := assetService.jobStore.FindJob(id)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return JobNotFoundError
+	}
+	if requester.User != job.AssetFile.Conversation.User && !assetService.isApprover(requester) {
+		return NotCancellerError
+	}
+
+	assetService.pendingMutex.Lock()
+	pending, ok := assetService.pendingJobs[id]
+	if ok {
+		delete(assetService.pendingJobs, id)
+		pending.timer.Stop()
+	}
+	assetService.pendingMutex.Unlock()
+
+	if ok {
+		result := model.ApprovalResult{Outcome: model.ApprovalCancelled, User: requester.User}
+		assetService.closeApproval(ctx, pending, result)
+
+		pending.job.cleanup()
+		_ = assetService.fail(ctx, pending.job.progress, pending.job.assetFile.Conversation, JobCancelledError)
+		return nil
+	}
+
+	if !assetService.queue.Cancel(id) {
+		return NotCancellableError
+	}
+	return nil
+}
+
+// unpublishedTargets returns the targets the job has no pull request in.
+func unpublishedTargets(job model.Job, targets []Target) []Target {
+	unpublished := make([]Target, 0, len(targets))
+	for _, target := range targets {
+		key := targetKey(target.Route, targetBaseBranch(target.Route, job.AssetFile.Message.Directives))
+
+		published := false
+		for _, state := range job.Targets {
+			if targetMatches(state, target.Route, key) && state.PullRequest != nil {
+				published = true
+			}
+		}
+		if !published {
+			unpublished = append(unpublished, target)
+		}
+	}
+	return unpublished
+}
+
+// UnfinishedJobs returns the jobs that were running when the bot stopped.
+func (assetService *AssetSetviceImpl) UnfinishedJobs() ([]model.Job, error) {
+	jobs, err := assetService.jobStore.ListJobs(0)
+	if err != nil {
+		return nil, err
+	}
+
+	unfinished := make([]model.Job, 0, len(jobs))
+	for _, job := range jobs {
+		if JobUnfinished(job) {
+			unfinished = append(unfinished, job)
+		}
+	}
+	return unfinished, nil
+}
+
+// Resume queues an unfinished job again under the same id. The upload is
+// downloaded and extracted again, and the routes that already have a commit
+// or a pull request keep them.
+func (assetService *AssetSetviceImpl) Resume(ctx context.Context, record model.Job, targets []Target) error {
+	if !JobUnfinished(record) {
+		return NotResumableError
+	}
+
+	conversation := record.AssetFile.Conversation
+
+	// The routes are only published after the approval, a job with progress
+	// in a route was approved.
+	approved := len(record.Targets) > 0
+
+	progress := assetService.newProgress(&record)
+	progress.stage(ctx, model.StageReceived)
+
+	if len(targets) == 0 {
+		return assetService.fail(ctx, progress, conversation, NoTargetError)
+	}
+
+	job := &assetJob{
+		id:        record.ID,
+		assetFile: record.AssetFile,
+		targets:   targets,
+		progress:  progress,
+		resumed:   true,
+		approved:  approved,
+	}
+	return assetService.enqueue(ctx, job, assetService.process)
+}
+
+// Stop drains the queue, see JobQueue.Stop, and then interrupts the jobs
+// waiting for an approval. Their files are deleted, every interrupted job is
+// downloaded again when it is resumed.
+func (assetService *AssetSetviceImpl) Stop(grace time.Duration) {
+	assetService.queue.Stop(grace)
+
+	assetService.pendingMutex.Lock()
+	pendingJobs := assetService.pendingJobs
+	assetService.pendingJobs = map[string]*pendingJob{}
+	assetService.pendingMutex.Unlock()
+
+	for _, pending := range pendingJobs {
+		pending.timer.Stop()
+		pending.job.cleanup()
+		_ = assetService.interrupt(pending.job.progress)
+	}
+}
+
+// interrupt saves the job to be resumed on the next start. The uploader isn't
+// told, the job goes on from where it stopped.
+func (assetService *AssetSetviceImpl) interrupt(progress *progress) error {
+	log.Printf("interrupting the job %s in %s\n", progress.record.ID, progress.record.State)
+	progress.transition(model.JobStateInterrupted)
+	return JobInterruptedError
+}
+
+// interrupted tells whether the job stopped because the bot is going down
+// rather than on an error of its own.
+func (assetService *AssetSetviceImpl) interrupted(ctx context.Context, err error) bool {
+	if errors.Is(err, JobInterruptedError) {
+		return true
+	}
+	return assetService.queue.stopping() && errors.Is(ctx.Err(), context.Canceled)
+}
+
+// JobUnfinished tells whether the job stopped before it was done. The jobs
+// recorded without a state are from before the states existed and are never
+// resumed.
+func JobUnfinished(job model.Job) bool {
+	switch job.State {
+	case "", model.JobStateNotified, model.JobStateFailed:
+		return false
+	}
+	return true
+}
+
+// JobStage returns the last stage the job reached.
+func JobStage(job model.Job) model.Stage {
+	if len(job.Status.Stages) == 0 {
+		return model.StageReceived
+	}
+	return job.Status.Stages[len(job.Status.Stages)-1].Stage
+}
+
+func JobFailed(job model.Job) bool {
+	return JobStage(job) == model.StageFailed
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/core/model"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/core/port/out"
+	"log"
+	"strings"
+	"time"
+)
+
+var (
+	ChecksFailedError     = fmt.Errorf("the checks of the pull request failed")
+	ChecksTimeoutError    = fmt.Errorf("timed out waiting for the checks of the pull request")
+	InvalidAutoMergeError = fmt.Errorf("invalid auto-merge configuration")
+)
+
+// NewAutoMergeConfig validates the auto-merge settings, filling the defaults
+// of the optional durations.
+func NewAutoMergeConfig(mode, method string, pollInterval, timeout, checksGracePeriod time.Duration) (model.AutoMergeConfig, error) {
+	config := model.AutoMergeConfig{
+		Mode:              model.AutoMergeMode(mode),
+		Method:            model.MergeMethod(method),
+		PollInterval:      pollInterval,
+		Timeout:           timeout,
+		ChecksGracePeriod: checksGracePeriod,
+	}
+
+	switch config.Mode {
+	case model.AutoMergeDisabled, model.AutoMergeGithub, model.AutoMergePoll:
+	default:
+		return config, fmt.Errorf("%w: unknown mode %q", InvalidAutoMergeError, mode)
+	}
+
+	switch config.Method {
+	case "":
+		config.Method = model.MergeMethodSquash
+	case model.MergeMethodMerge, model.MergeMethodSquash, model.MergeMethodRebase:
+	default:
+		return config, fmt.Errorf("%w: unknown merge method %q", InvalidAutoMergeError, method)
+	}
+
+	if config.PollInterval <= 0 {
+		config.PollInterval = 30 * time.Second
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 30 * time.Minute
+	}
+	if config.ChecksGracePeriod <= 0 {
+		config.ChecksGracePeriod = time.Minute
+	}
+
+	return config, nil
+}
+
+func (assetService *AssetSetviceImpl) startAutoMerge(ctx context.Context, conversation model.Conversation, target Target,
+	pullRequest model.PullRequest) {
+	config := target.Route.AutoMerge
+
+	switch config.Mode {
+	case model.AutoMergeGithub:
+		if err := assetService.enableAutoMerge(ctx, target.VCSClient, config, pullRequest); err != nil {
+			_ = assetService.sendMessage(ctx, conversation, "Error to enable the auto-merge", err.Error(), model.ErrorMessage)
+			return
+		}
+		message := fmt.Sprintf("%s will be merged with %s once the checks pass", pullRequest.Url, config.Method)
+		_ = assetService.sendMessage(ctx, conversation, "Auto-merge enabled", message, model.SuccessMessage)
+	case model.AutoMergePoll:
+		go assetService.mergeWhenGreen(assetService.queue.background(), conversation, target.VCSClient, config, pullRequest)
+	}
+}
+
+func (assetService *AssetSetviceImpl) enableAutoMerge(ctx context.Context, vcsClient out.VersionControlSystem,
+	config model.AutoMergeConfig, pullRequest model.PullRequest) error {
+	ctx, cancel := withTimeout(ctx, assetService.timeouts.PullRequest)
+	defer cancel()
+
+	return vcsClient.EnableAutoMerge(ctx, pullRequest, config.Method)
+}
+
+// mergeWhenGreen polls the checks of the pull request and merges it once all
+// of them pass. A pull request without any check is only merged after the
+// grace period, so checks that take a while to be registered aren't skipped.
+// The polling stops silently when the context is done, the bot is going down.
+func (assetService *AssetSetviceImpl) mergeWhenGreen(ctx context.Context, conversation model.Conversation,
+	vcsClient out.VersionControlSystem, config model.AutoMergeConfig, pullRequest model.PullRequest) {
+	started := time.Now()
+	deadline := started.Add(config.Timeout)
+
+	ticker := time.NewTicker(config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if time.Now().After(deadline) {
+			assetService.sendAutoMergeError(ctx, conversation, pullRequest, ChecksTimeoutError)
+			return
+		}
+
+		status, err := assetService.checksStatus(ctx, vcsClient, pullRequest)
+		if err != nil {
+			log.Printf("error to get the checks of %s: %v\n", pullRequest.Url, err)
+			continue
+		}
+
+		switch {
+		case status.State == model.ChecksFailure:
+			assetService.sendAutoMergeError(ctx, conversation, pullRequest, fmt.Errorf("%w: %s", ChecksFailedError, strings.Join(status.Failed, ", ")))
+			return
+		case status.State == model.ChecksPending:
+			continue
+		case status.Total == 0 && time.Since(started) < config.ChecksGracePeriod:
+			continue
+		}
+
+		if err = assetService.mergePullRequest(ctx, vcsClient, config, pullRequest); err != nil {
+			assetService.sendAutoMergeError(ctx, conversation, pullRequest, err)
+			return
+		}
+
+		message := fmt.Sprintf("%s was merged with %s after the checks passed", pullRequest.Url, config.Method)
+		_ = assetService.sendMessage(ctx, conversation, "Pull request merged", message, model.SuccessMessage)
+		return
+	}
+}
+
+// checksStatus retries the temporary errors of the checks, reading them has
+// no side effect.
+func (assetService *AssetSetviceImpl) checksStatus(ctx context.Context, vcsClient out.VersionControlSystem,
+	pullRequest model.PullRequest) (status model.ChecksStatus, err error) {
+	_, err = assetService.retryPolicy.do(ctx, assetService.timeouts.PullRequest, true, func(ctx context.Context) (err error) {
+		status, err = vcsClient.GetChecksStatus(ctx, pullRequest)
+		return err
+	})
+	return status, err
+}
+
+func (assetService *AssetSetviceImpl) mergePullRequest(ctx context.Context, vcsClient out.VersionControlSystem,
+	config model.AutoMergeConfig, pullRequest model.PullRequest) error {
+	ctx, cancel := withTimeout(ctx, assetService.timeouts.PullRequest)
+	defer cancel()
+
+	return vcsClient.MergePullRequest(ctx, pullRequest, config.Method)
+}
+
+func (assetService *AssetSetviceImpl) sendAutoMergeError(ctx context.Context, conversation model.Conversation,
+	pullRequest model.PullRequest, er error) {
+	message := fmt.Sprintf("%s was not merged: %v", pullRequest.Url, er)
+	_ = assetService.sendMessage(ctx, conversation, "Error to auto-merge the pull request", message, model.ErrorMessage)
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"fmt"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/core/model"
+	"regexp"
+	"strings"
+)
+
+var (
+	directiveRegex   = regexp.MustCompile(`^([A-Za-z][A-Za-z_-]*)\s*:\s*(.*)$`)
+	chatMentionRegex = regexp.MustCompile(`<@!?([^<>|]+)(?:\|([^<>]*))?>`)
+)
+
+var ChatMentionReviewerError = fmt.Errorf("the reviewers must be GitHub users or teams, a chat mention can't review a pull request")
+
+// ParseAssetMessage splits the text sent with an upload into a title (the
+// first line), a free-form description and the known `key: value`
+// directives. Lines with unknown keys are kept in the description. The
+// mentions of the chat users, as <@U123>, are written as @U123 in the title
+// and the description.
+func ParseAssetMessage(text string) model.AssetMessage {
+	var message model.AssetMessage
+	var description []string
+
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		if matches := directiveRegex.FindStringSubmatch(trimmed); matches != nil {
+			if applyDirective(&message.Directives, matches[1], strings.TrimSpace(matches[2])) {
+				continue
+			}
+		}
+
+		if message.Title == "" {
+			if trimmed != "" {
+				message.Title = plainMentions(trimmed)
+			}
+			continue
+		}
+
+		description = append(description, plainMentions(line))
+	}
+
+	message.Description = strings.TrimSpace(strings.Join(description, "\n"))
+	return message
+}
+
+func applyDirective(directives *model.AssetDirectives, key, value string) bool {
+	switch strings.ToLower(strings.ReplaceAll(key, "_", "-")) {
+	case "path", "target", "target-path":
+		directives.TargetPath = strings.Trim(value, "/")
+	case "reviewer", "reviewers":
+		directives.Reviewers = append(directives.Reviewers, SplitReviewers(value)...)
+	case "base", "base-branch":
+		directives.BaseBranch = value
+	default:
+		return false
+	}
+	return true
+}
+
+// plainMentions writes the chat mentions with their label, or with their id
+// when they have none.
+func plainMentions(text string) string {
+	return chatMentionRegex.ReplaceAllStringFunc(text, func(mention string) string {
+		matches := chatMentionRegex.FindStringSubmatch(mention)
+		if matches[2] != "" {
+			return "@" + matches[2]
+		}
+		return "@" + matches[1]
+	})
+}
+
+// checkReviewers refuses the chat mentions, only the GitHub users and teams
+// can be asked for a review.
+func checkReviewers(reviewers []string) error {
+	for _, reviewer := range reviewers {
+		if strings.HasPrefix(reviewer, "<@") {
+			return fmt.Errorf("%w: %s", ChatMentionReviewerError, plainMentions(reviewer))
+		}
+	}
+	return nil
+}
+
+// SplitReviewers reads a list of reviewers separated by commas or spaces,
+// dropping the @ of the mentions. It is exported for the upload form.
+func SplitReviewers(value string) []string {
+	fields := strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n'
+	})
+
+	reviewers := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if reviewer := strings.TrimPrefix(field, "@"); reviewer != "" {
+			reviewers = append(reviewers, reviewer)
+		}
+	}
+	return reviewers
+}
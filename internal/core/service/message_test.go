@@ -0,0 +1,43 @@
+package service
+
+import (
+	"errors"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/core/model"
+	"reflect"
+	"testing"
+)
+
+func TestParseAssetMessageWritesMentionsAsText(t *testing.T) {
+	message := ParseAssetMessage("Icons for <@U04ABC>\nAsked by <@U05DEF|jane>\nreviewers: octocat, acme/design")
+
+	if message.Title != "Icons for @U04ABC" {
+		t.Errorf("title %q", message.Title)
+	}
+	if message.Description != "Asked by @jane" {
+		t.Errorf("description %q", message.Description)
+	}
+	if want := []string{"octocat", "acme/design"}; !reflect.DeepEqual(message.Directives.Reviewers, want) {
+		t.Errorf("reviewers %v, want %v", message.Directives.Reviewers, want)
+	}
+}
+
+func TestValidateAssetFileRefusesMentionedReviewers(t *testing.T) {
+	assetFile := model.AssetFile{
+		Url:       "https://files.example/icons.zip",
+		Extension: "zip",
+		Message:   ParseAssetMessage("Icons\nreviewers: octocat <@U04ABC>"),
+	}
+
+	err := ValidateAssetFile(assetFile)
+	if !errors.Is(err, ChatMentionReviewerError) {
+		t.Fatalf("expected a mention error, got %v", err)
+	}
+	if want := ChatMentionReviewerError.Error() + ": @U04ABC"; err.Error() != want {
+		t.Errorf("error %q, want %q", err.Error(), want)
+	}
+
+	assetFile.Message = ParseAssetMessage("Icons\nreviewers: @octocat")
+	if err = ValidateAssetFile(assetFile); err != nil {
+		t.Errorf("a GitHub user was refused: %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/core/model"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/core/port/in"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/core/port/out"
+)
+
+type NotificationService interface {
+	Notify(ctx context.Context, event model.PullRequestEvent) error
+}
+
+type NotificationServiceImpl struct {
+	messageClient     in.MessageSystem
+	conversationStore out.ConversationStore
+}
+
+func NewNotificationService(messageClient in.MessageSystem, conversationStore out.ConversationStore) NotificationService {
+	return &NotificationServiceImpl{
+		messageClient:     messageClient,
+		conversationStore: conversationStore,
+	}
+}
+
+// Notify posts the event into the conversation where the upload of the pull
+// request happened. Events of branches the bot didn't create are ignored.
+func (notificationService *NotificationServiceImpl) Notify(ctx context.Context, event model.PullRequestEvent) error {
+	thread, found, err := notificationService.conversationStore.FindPullRequestThread(event.Repository, event.Branch)
+	if err != nil || !found {
+		return err
+	}
+
+	url := event.Url
+	if url == "" {
+		url = thread.Url
+	}
+
+	title, style := notificationTitle(event.Kind)
+	message := url
+	if event.Actor != "" && event.Kind != model.PullRequestChecksFailed {
+		message = fmt.Sprintf("%s by %s", url, event.Actor)
+	}
+
+	return notificationService.messageClient.PublishMessage(ctx, model.Message{
+		Title:        title,
+		Message:      message,
+		Style:        style,
+		Conversation: thread.Conversation,
+	})
+}
+
+func notificationTitle(kind model.PullRequestEventKind) (string, model.MessageStyle) {
+	switch kind {
+	case model.PullRequestApproved:
+		return "Pull request approved", model.SuccessMessage
+	case model.PullRequestChangesRequested:
+		return "Changes requested on the pull request", model.ErrorMessage
+	case model.PullRequestMerged:
+		return "Pull request merged", model.SuccessMessage
+	case model.PullRequestClosed:
+		return "Pull request closed without merging", model.ErrorMessage
+	default:
+		return "The checks of the pull request failed", model.ErrorMessage
+	}
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/core/model"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/core/port/in"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/core/port/out"
+	"log"
+	"time"
+)
+
+// progress reports the stages of a job to the uploader, reacting to the
+// upload and keeping a single status message up to date with the time spent
+// in each stage. The record of the job is saved on every change.
+type progress struct {
+	messageClient in.MessageSystem
+	jobStore      out.JobStore
+	timeout       time.Duration
+	record        *model.Job
+	conversation  model.Conversation
+	statusID      string
+	status        model.Status
+	reported      model.Stage
+	started       time.Time
+	stageStarted  time.Time
+}
+
+// newProgress starts the status of the job over. The last stage of a job
+// that ran before is kept, so its reaction is replaced by the next one.
+func newProgress(messageClient in.MessageSystem, jobStore out.JobStore, record *model.Job, timeout time.Duration) *progress {
+	progress := &progress{
+		messageClient: messageClient,
+		jobStore:      jobStore,
+		timeout:       timeout,
+		record:        record,
+		conversation:  record.AssetFile.Conversation,
+		status:        model.Status{JobID: record.ID},
+		started:       time.Now(),
+	}
+
+	if stages := record.Status.Stages; len(stages) > 0 {
+		progress.reported = stages[len(stages)-1].Stage
+	}
+	return progress
+}
+
+func (progress *progress) elapsed() time.Duration {
+	return time.Since(progress.started)
+}
+
+// stage closes the current stage and starts the given one.
+func (progress *progress) stage(ctx context.Context, stage model.Stage) {
+	progress.closeStage()
+	progress.status.Stages = append(progress.status.Stages, model.StageTiming{Stage: stage})
+	progress.stageStarted = time.Now()
+	progress.report(ctx, stage)
+}
+
+// done closes the current stage and reports the final one, which has no
+// duration.
+func (progress *progress) done(ctx context.Context, stage model.Stage) {
+	progress.closeStage()
+	progress.status.Stages = append(progress.status.Stages, model.StageTiming{Stage: stage, Done: true})
+	progress.report(reportContext(ctx), stage)
+}
+
+func (progress *progress) fail(ctx context.Context, err error) {
+	progress.status.Error = err.Error()
+	progress.record.State = model.JobStateFailed
+	progress.done(ctx, model.StageFailed)
+}
+
+// transition saves the job in its new state.
+func (progress *progress) transition(state model.JobState) {
+	progress.record.State = state
+	progress.save()
+}
+
+// attempts adds the calls made in a step, saved with the next change.
+func (progress *progress) attempts(step string, attempts int) {
+	if progress.record.Attempts == nil {
+		progress.record.Attempts = map[string]int{}
+	}
+	progress.record.Attempts[step] += attempts
+}
+
+// target returns the progress of the job in the route, added to the record
+// on first use. The progress saved before the keys existed is found by the
+// name of the route.
+func (progress *progress) target(route model.Route, baseBranch string) *model.JobTarget {
+	key := targetKey(route, baseBranch)
+
+	for i := range progress.record.Targets {
+		target := &progress.record.Targets[i]
+		if targetMatches(*target, route, key) {
+			target.Key = key
+			target.Route = route.Name
+			return target
+		}
+	}
+
+	progress.record.Targets = append(progress.record.Targets, model.JobTarget{Key: key, Route: route.Name})
+	return &progress.record.Targets[len(progress.record.Targets)-1]
+}
+
+// targetKey names the repository, the base branch and the target path of a
+// route, see model.JobTarget.
+func targetKey(route model.Route, baseBranch string) string {
+	return fmt.Sprintf("%s/%s:%s:%s", route.Owner, route.Repository, baseBranch, route.TargetPath)
+}
+
+func targetMatches(target model.JobTarget, route model.Route, key string) bool {
+	return target.Key == key || (target.Key == "" && target.Route == route.Name)
+}
+
+func (progress *progress) closeStage() {
+	if len(progress.status.Stages) == 0 {
+		return
+	}
+
+	current := &progress.status.Stages[len(progress.status.Stages)-1]
+	if !current.Done {
+		current.Duration = time.Since(progress.stageStarted)
+		current.Done = true
+	}
+}
+
+// report never fails the job, a reaction or status that can't be posted is
+// only logged.
+func (progress *progress) report(ctx context.Context, stage model.Stage) {
+	ctx, cancel := withTimeout(ctx, progress.timeout)
+	defer cancel()
+
+	if err := progress.messageClient.ReportStage(ctx, progress.conversation, progress.reported, stage); err != nil {
+		log.Printf("error to report the stage %s: %v\n", stage, err)
+	}
+	progress.reported = stage
+
+	var err error
+	if progress.statusID == "" {
+		progress.statusID, err = progress.messageClient.PublishStatus(ctx, progress.conversation, progress.status)
+	} else {
+		err = progress.messageClient.UpdateStatus(ctx, progress.conversation, progress.statusID, progress.status)
+	}
+	if err != nil {
+		log.Printf("error to publish the status: %v\n", err)
+	}
+
+	progress.record.Status = progress.status
+	progress.save()
+}
+
+func (progress *progress) save() {
+	progress.record.UpdatedAt = time.Now()
+	if err := progress.jobStore.SaveJob(*progress.record); err != nil {
+		log.Printf("error to save the job %s: %v\n", progress.record.ID, err)
+	}
+}
@@ -0,0 +1,307 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+var (
+	QueueFullError      = fmt.Errorf("the bot is busy with other uploads, try again in a few minutes")
+	JobTimeoutError     = fmt.Errorf("the upload took too long and was stopped")
+	JobInterruptedError = fmt.Errorf("the bot stopped before the upload was done, it is resumed once the bot is back")
+	JobPanicError       = fmt.Errorf("the upload stopped on an unexpected error")
+	JobCancelledError   = fmt.Errorf("the upload was cancelled")
+)
+
+// cancelWindDown bounds the wait for the cancelled jobs on stop, a call that
+// ignores its context can't hold the bot.
+const cancelWindDown = 5 * time.Second
+
+// task is a step of a job run by the workers of the queue. fail reports the
+// job as failed when the task panics, interrupt saves a task that was still
+// waiting when the queue stopped.
+type task struct {
+	jobID     string
+	run       func(ctx context.Context) error
+	fail      func(ctx context.Context, err error)
+	interrupt func()
+}
+
+// queuedJob is a job waiting in the queue or running, cancel is set once a
+// worker runs it. cancelled is closed when the job is cancelled.
+type queuedJob struct {
+	cancel    context.CancelFunc
+	cancelled chan struct{}
+}
+
+type queuedJobKey struct{}
+
+// JobQueue runs the jobs on a fixed number of workers, so a large upload
+// doesn't hold the others and a panic doesn't stop the bot. A job that finds
+// the queue full is refused instead of waiting. The jobs are kept by id
+// until they are done, so one can be cancelled on its own.
+type JobQueue struct {
+	tasks   chan task
+	workers int
+	timeout time.Duration
+	ctx     context.Context
+	cancel  context.CancelFunc
+	running sync.WaitGroup
+	mutex   sync.Mutex
+	stopped bool
+	stop    chan struct{}
+	jobs    map[string]*queuedJob
+}
+
+// NewJobQueue creates a queue of size jobs waiting for the workers. Each job
+// runs for timeout at most, or without limit when it is zero.
+func NewJobQueue(workers, size int, timeout time.Duration) *JobQueue {
+	if workers < 1 {
+		workers = 1
+	}
+	if size < 0 {
+		size = 0
+	}
+
+	return &JobQueue{
+		tasks:   make(chan task, size),
+		workers: workers,
+		timeout: timeout,
+		stop:    make(chan struct{}),
+		jobs:    map[string]*queuedJob{},
+	}
+}
+
+// Start runs the workers until the context is done or the queue is stopped.
+// The context of every job is derived from it, so cancelling it stops the
+// jobs in progress.
+func (queue *JobQueue) Start(ctx context.Context) {
+	queue.ctx, queue.cancel = context.WithCancel(ctx)
+	for i := 0; i < queue.workers; i++ {
+		queue.running.Add(1)
+		go queue.work(queue.ctx)
+	}
+}
+
+func (queue *JobQueue) work(ctx context.Context) {
+	defer queue.running.Done()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-queue.stop:
+			return
+		case task := <-queue.tasks:
+			if queue.stopping() {
+				queue.forget(task.jobID, queue.job(task.jobID))
+				task.interrupt()
+				return
+			}
+			queue.execute(ctx, task)
+		}
+	}
+}
+
+// Stop refuses the new jobs and gives the running ones the grace period to
+// finish. The jobs still running after it are cancelled and get
+// cancelWindDown to save their state, the ones still waiting in the queue are
+// interrupted.
+func (queue *JobQueue) Stop(grace time.Duration) {
+	queue.mutex.Lock()
+	if !queue.stopped {
+		queue.stopped = true
+		close(queue.stop)
+	}
+	queue.mutex.Unlock()
+
+	if !waitFor(&queue.running, grace) {
+		log.Printf("the jobs didn't finish in %s, interrupting them\n", grace)
+	}
+	if queue.cancel != nil {
+		queue.cancel()
+	}
+	if !waitFor(&queue.running, cancelWindDown) {
+		log.Printf("the jobs didn't stop %s after they were cancelled, leaving them\n", cancelWindDown)
+	}
+
+	for {
+		select {
+		case task := <-queue.tasks:
+			queue.forget(task.jobID, queue.job(task.jobID))
+			task.interrupt()
+		default:
+			return
+		}
+	}
+}
+
+// Cancel stops the job, right away when it runs and before it starts when it
+// is waiting. It tells whether the job was in the queue.
+func (queue *JobQueue) Cancel(jobID string) bool {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	job, ok := queue.jobs[jobID]
+	if !ok {
+		return false
+	}
+
+	select {
+	case <-job.cancelled:
+	default:
+		close(job.cancelled)
+	}
+	if job.cancel != nil {
+		job.cancel()
+	}
+	return true
+}
+
+func (queue *JobQueue) stopping() bool {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	return queue.stopped
+}
+
+// job returns the entry of the job, nil when it isn't kept.
+func (queue *JobQueue) job(jobID string) *queuedJob {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	return queue.jobs[jobID]
+}
+
+// forget drops the entry of the job once it is done. A job queued again under
+// the same id, as an approved one, keeps its new entry.
+func (queue *JobQueue) forget(jobID string, job *queuedJob) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	if queue.jobs[jobID] == job {
+		delete(queue.jobs, jobID)
+	}
+}
+
+func (queue *JobQueue) execute(ctx context.Context, task task) {
+	job := queue.job(task.jobID)
+	if job == nil {
+		job = &queuedJob{cancelled: make(chan struct{})}
+	}
+	defer queue.forget(task.jobID, job)
+
+	ctx, cancelJob := context.WithCancel(context.WithValue(ctx, queuedJobKey{}, job))
+	defer cancelJob()
+
+	queue.mutex.Lock()
+	job.cancel = cancelJob
+	select {
+	case <-job.cancelled:
+		cancelJob()
+	default:
+	}
+	queue.mutex.Unlock()
+
+	cancel := func() {}
+	if queue.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, queue.timeout)
+	}
+	defer cancel()
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			log.Printf("panic in the job %s: %v\n%s", task.jobID, recovered, debug.Stack())
+			task.fail(ctx, fmt.Errorf("%w: %v", JobPanicError, recovered))
+		}
+	}()
+
+	if err := task.run(ctx); err != nil {
+		log.Printf("error to process the job %s: %v\n", task.jobID, err)
+	}
+}
+
+// enqueue never blocks, the task is refused when the queue is full or
+// stopped.
+func (queue *JobQueue) enqueue(task task) error {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	if queue.stopped {
+		return JobInterruptedError
+	}
+
+	select {
+	case queue.tasks <- task:
+		queue.jobs[task.jobID] = &queuedJob{cancelled: make(chan struct{})}
+		return nil
+	default:
+		return QueueFullError
+	}
+}
+
+// background is the context of the work that outlives a job, like waiting for
+// an approval or for the checks of a pull request. It is done when the queue
+// stops.
+func (queue *JobQueue) background() context.Context {
+	if queue.ctx == nil {
+		return context.Background()
+	}
+	return queue.ctx
+}
+
+// waitFor waits for the group for timeout at most, telling whether it was
+// done.
+func waitFor(group *sync.WaitGroup, timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		group.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// withTimeout bounds the context by the timeout, when there is one.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// reportContext is the context of the messages that report the end of a job.
+// A job stopped by its timeout still tells the uploader, so the messages get
+// a context of their own once the one of the job is done.
+func reportContext(ctx context.Context) context.Context {
+	if ctx.Err() != nil {
+		return context.Background()
+	}
+	return ctx
+}
+
+// jobError turns the end of the context of a job into the error reported to
+// the uploader.
+func jobError(ctx context.Context) error {
+	switch ctx.Err() {
+	case nil:
+		return nil
+	case context.DeadlineExceeded:
+		return JobTimeoutError
+	}
+
+	if job, ok := ctx.Value(queuedJobKey{}).(*queuedJob); ok {
+		select {
+		case <-job.cancelled:
+			return JobCancelledError
+		default:
+		}
+	}
+	return JobInterruptedError
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// TemporaryError is an error of a port that may succeed when tried again.
+// RetryAfter is how long the other side asked to wait, zero when it didn't
+// say. RateLimited tells the call was refused before it was applied, so it
+// can be sent again even when it isn't idempotent.
+type TemporaryError struct {
+	Err         error
+	RetryAfter  time.Duration
+	RateLimited bool
+}
+
+func (temporaryError *TemporaryError) Error() string {
+	return temporaryError.Err.Error()
+}
+
+func (temporaryError *TemporaryError) Unwrap() error {
+	return temporaryError.Err
+}
+
+// RetryPolicy is how many times a call is attempted and how long to wait
+// between the attempts. The waits grow exponentially from BaseDelay with a
+// random jitter and never go over MaxDelay, a call asked to wait longer
+// fails right away instead of holding the worker.
+type RetryPolicy struct {
+	Attempts  int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// do calls the port until it succeeds, fails with an error that isn't
+// temporary or runs out of attempts, and returns the number of attempts. Each
+// attempt is bounded by timeout, so a call that hangs is tried again. A call
+// that isn't idempotent is only repeated when it was rate limited, it may have
+// been applied before a transient error.
+func (policy RetryPolicy) do(ctx context.Context, timeout time.Duration, idempotent bool,
+	call func(ctx context.Context) error) (int, error) {
+	for attempt := 1; ; attempt++ {
+		callCtx, cancel := withTimeout(ctx, timeout)
+		err := call(callCtx)
+		cancel()
+
+		var temporary *TemporaryError
+		if err == nil || !errors.As(err, &temporary) || attempt >= policy.Attempts {
+			return attempt, err
+		}
+		if !idempotent && !temporary.RateLimited {
+			return attempt, err
+		}
+
+		delay, ok := policy.delay(attempt, temporary.RetryAfter)
+		if !ok {
+			return attempt, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return attempt, err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// delay is the wait before the next attempt. The wait the other side asked
+// for is honored, with a little jitter so the workers don't all come back at
+// once.
+func (policy RetryPolicy) delay(attempt int, retryAfter time.Duration) (time.Duration, bool) {
+	if retryAfter > 0 {
+		if retryAfter > policy.MaxDelay {
+			return 0, false
+		}
+		return retryAfter + jitter(policy.BaseDelay), true
+	}
+
+	backoff := policy.BaseDelay << uint(attempt-1)
+	if backoff <= 0 || backoff > policy.MaxDelay {
+		backoff = policy.MaxDelay
+	}
+	return jitter(backoff), true
+}
+
+// jitter returns a random duration up to limit.
+func jitter(limit time.Duration) time.Duration {
+	if limit <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(limit))) + 1
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/gofrs/uuid"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/core/model"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/util/fileutil"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+var (
+	InvalidTemplateError   = fmt.Errorf("invalid template")
+	InvalidBranchNameError = fmt.Errorf("invalid branch name")
+)
+
+var slugRegex = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Templates holds the parsed templates used to build the branch name, the
+// commit message and the pull request of an asset job.
+type Templates struct {
+	branch        *template.Template
+	commitMessage *template.Template
+	prTitle       *template.Template
+	prDescription *template.Template
+}
+
+// NewTemplates parses the given templates and validates them by rendering a
+// sample job for each base branch, so a broken template, or a branch template
+// that renders the base branch, is reported at startup instead of on the
+// first upload.
+func NewTemplates(branch, commitMessage, prTitle, prDescription string, baseBranches []string) (*Templates, error) {
+	templates := &Templates{}
+
+	var err error
+	if templates.branch, err = parseTemplate("branch", branch); err != nil {
+		return nil, err
+	}
+	if templates.commitMessage, err = parseTemplate("commit message", commitMessage); err != nil {
+		return nil, err
+	}
+	if templates.prTitle, err = parseTemplate("pr title", prTitle); err != nil {
+		return nil, err
+	}
+	if templates.prDescription, err = parseTemplate("pr description", prDescription); err != nil {
+		return nil, err
+	}
+
+	data := sampleTemplateData()
+	for _, baseBranch := range append([]string{data.BaseBranch}, baseBranches...) {
+		data.BaseBranch = baseBranch
+
+		rendered, err := templates.Render(data)
+		if err != nil {
+			return nil, err
+		}
+		if rendered.Branch == baseBranch {
+			return nil, fmt.Errorf("%w: the branch template renders the base branch %s", InvalidTemplateError, baseBranch)
+		}
+	}
+
+	return templates, nil
+}
+
+// Render executes every template with the given data.
+func (templates *Templates) Render(data model.TemplateData) (model.RenderedTemplates, error) {
+	var rendered model.RenderedTemplates
+	var err error
+
+	if rendered.Branch, err = executeTemplate(templates.branch, data); err != nil {
+		return rendered, err
+	}
+	rendered.Branch = strings.TrimSpace(rendered.Branch)
+	if err = validateBranchName(rendered.Branch); err != nil {
+		return rendered, err
+	}
+
+	if rendered.CommitMessage, err = executeTemplate(templates.commitMessage, data); err != nil {
+		return rendered, err
+	}
+	if strings.TrimSpace(rendered.CommitMessage) == "" {
+		return rendered, fmt.Errorf("%w: the commit message template rendered an empty message", InvalidTemplateError)
+	}
+
+	if rendered.PRTitle, err = executeTemplate(templates.prTitle, data); err != nil {
+		return rendered, err
+	}
+	rendered.PRTitle = strings.TrimSpace(rendered.PRTitle)
+
+	if rendered.PRDescription, err = executeTemplate(templates.prDescription, data); err != nil {
+		return rendered, err
+	}
+
+	return rendered, nil
+}
+
+func parseTemplate(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs()).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", InvalidTemplateError, name, err)
+	}
+	return tmpl, nil
+}
+
+func executeTemplate(tmpl *template.Template, data model.TemplateData) (string, error) {
+	var buffer bytes.Buffer
+	if err := tmpl.Execute(&buffer, data); err != nil {
+		return "", fmt.Errorf("%w: %s: %v", InvalidTemplateError, tmpl.Name(), err)
+	}
+	return buffer.String(), nil
+}
+
+func templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"lower":     strings.ToLower,
+		"upper":     strings.ToUpper,
+		"trim":      strings.TrimSpace,
+		"slug":      slug,
+		"trunc":     trunc,
+		"date":      date,
+		"humanSize": fileutil.HumanSize,
+		"uuid":      newUUID,
+	}
+}
+
+// slug turns a free text into something safe to use in a branch name.
+func slug(text string) string {
+	return strings.Trim(slugRegex.ReplaceAllString(strings.ToLower(text), "-"), "-")
+}
+
+func trunc(length int, text string) string {
+	runes := []rune(text)
+	if len(runes) <= length {
+		return text
+	}
+	return strings.TrimRight(string(runes[:length]), "-")
+}
+
+func date(layout string, value time.Time) string {
+	return value.Format(layout)
+}
+
+func newUUID() (string, error) {
+	u4, err := uuid.NewV4()
+	if err != nil {
+		return "", err
+	}
+	return u4.String(), nil
+}
+
+// validateBranchName applies the subset of git check-ref-format rules that a
+// rendered template can realistically break.
+func validateBranchName(branch string) error {
+	switch {
+	case branch == "":
+		return fmt.Errorf("%w: the branch name is empty", InvalidBranchNameError)
+	case strings.ContainsAny(branch, " ~^:?*[\\\t\n"):
+		return fmt.Errorf("%w: %q has forbidden characters", InvalidBranchNameError, branch)
+	case strings.Contains(branch, "..") || strings.Contains(branch, "//") || strings.Contains(branch, "@{"):
+		return fmt.Errorf("%w: %q has a forbidden sequence", InvalidBranchNameError, branch)
+	case strings.HasPrefix(branch, "/") || strings.HasSuffix(branch, "/") || strings.HasSuffix(branch, "."):
+		return fmt.Errorf("%w: %q can't start or end with a slash or end with a dot", InvalidBranchNameError, branch)
+	case strings.HasSuffix(branch, ".lock"):
+		return fmt.Errorf("%w: %q can't end with .lock", InvalidBranchNameError, branch)
+	}
+	return nil
+}
+
+func sampleTemplateData() model.TemplateData {
+	return model.TemplateData{
+		Uploader:    "jane",
+		Text:        "Onboarding icons\nNew icons for the onboarding flow\nreviewers: octocat",
+		Title:       "Onboarding icons",
+		Description: "New icons for the onboarding flow",
+		Route:       "web",
+		Repository:  "acme/web",
+		TargetPath:  "assets",
+		BaseBranch:  "main",
+		Reviewers:   []string{"octocat"},
+		Channel:     "C0000000000",
+		Timestamp:   time.Now(),
+		Upload: model.TemplateFile{
+			Name: "assets.zip",
+			Path: "assets.zip",
+			Size: 2048,
+		},
+		Files: []model.TemplateFile{
+			{Name: "icon.svg", Path: "assets/icons/icon.svg", Size: 1024},
+		},
+		FileCount: 1,
+		TotalSize: 1024,
+	}
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var InvalidTransformError = fmt.Errorf("invalid transform")
+
+var (
+	wordBoundaryRegex = regexp.MustCompile(`([a-z0-9])([A-Z])`)
+	separatorRegex    = regexp.MustCompile(`[^A-Za-z0-9]+`)
+)
+
+// transforms rename the files of an upload before they are committed, so each
+// repository gets the naming convention of its platform.
+var transforms = map[string]func(string) string{
+	"lowercase":  lowercaseTransform,
+	"kebab-case": func(filePath string) string { return caseTransform(filePath, "-") },
+	"snake-case": func(filePath string) string { return caseTransform(filePath, "_") },
+	"flatten":    path.Base,
+}
+
+func ValidateTransforms(names []string) error {
+	for _, name := range names {
+		if _, ok := transforms[name]; !ok {
+			return fmt.Errorf("%w: %q", InvalidTransformError, name)
+		}
+	}
+	return nil
+}
+
+func applyTransforms(filePath string, names []string) string {
+	for _, name := range names {
+		if transform, ok := transforms[name]; ok {
+			filePath = transform(filePath)
+		}
+	}
+	return filePath
+}
+
+func lowercaseTransform(filePath string) string {
+	return path.Join(path.Dir(filePath), strings.ToLower(path.Base(filePath)))
+}
+
+// caseTransform rewrites the file name, keeping its extension, with the words
+// joined by the separator.
+func caseTransform(filePath, separator string) string {
+	name := path.Base(filePath)
+	extension := path.Ext(name)
+	name = strings.TrimSuffix(name, extension)
+
+	name = wordBoundaryRegex.ReplaceAllString(name, "$1 $2")
+	name = strings.Trim(separatorRegex.ReplaceAllString(name, separator), separator)
+
+	return path.Join(path.Dir(filePath), strings.ToLower(name)+strings.ToLower(extension))
+}
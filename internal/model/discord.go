@@ -0,0 +1,114 @@
+package model
+
+import "time"
+
+type DiscordColor int
+
+const (
+	DiscordSuccess DiscordColor = 0x36a64f
+	DiscordError   DiscordColor = 0xe63939
+)
+
+type DiscordUser struct {
+	ID         string `json:"id"`
+	Username   string `json:"username"`
+	GlobalName string `json:"global_name"`
+	Bot        bool   `json:"bot"`
+}
+
+type DiscordAttachment struct {
+	ID          string `json:"id"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Url         string `json:"url"`
+	Size        int    `json:"size"`
+}
+
+type DiscordMessageReference struct {
+	MessageID string `json:"message_id,omitempty"`
+	ChannelID string `json:"channel_id,omitempty"`
+}
+
+type DiscordMessage struct {
+	ID               string                   `json:"id"`
+	ChannelID        string                   `json:"channel_id"`
+	GuildID          string                   `json:"guild_id"`
+	Author           DiscordUser              `json:"author"`
+	Content          string                   `json:"content"`
+	Timestamp        time.Time                `json:"timestamp"`
+	Attachments      []DiscordAttachment      `json:"attachments"`
+	MessageReference *DiscordMessageReference `json:"message_reference"`
+}
+
+type DiscordEmbedField struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type DiscordEmbedImage struct {
+	Url string `json:"url"`
+}
+
+type DiscordEmbedFooter struct {
+	Text string `json:"text"`
+}
+
+type DiscordEmbed struct {
+	Title       string              `json:"title,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Url         string              `json:"url,omitempty"`
+	Color       DiscordColor        `json:"color,omitempty"`
+	Fields      []DiscordEmbedField `json:"fields,omitempty"`
+	Image       *DiscordEmbedImage  `json:"image,omitempty"`
+	Footer      *DiscordEmbedFooter `json:"footer,omitempty"`
+}
+
+const (
+	DiscordActionRow = 1
+	DiscordButton    = 2
+)
+
+const (
+	DiscordButtonSuccess = 3
+	DiscordButtonDanger  = 4
+	DiscordButtonLink    = 5
+)
+
+type DiscordComponent struct {
+	Type       int                `json:"type"`
+	Style      int                `json:"style,omitempty"`
+	Label      string             `json:"label,omitempty"`
+	Url        string             `json:"url,omitempty"`
+	CustomID   string             `json:"custom_id,omitempty"`
+	Components []DiscordComponent `json:"components,omitempty"`
+}
+
+// DiscordOutgoingMessage is a message posted or edited by the bot. The
+// reference makes it a reply to the upload.
+type DiscordOutgoingMessage struct {
+	Content          string                   `json:"content"`
+	Embeds           []DiscordEmbed           `json:"embeds"`
+	Components       []DiscordComponent       `json:"components"`
+	MessageReference *DiscordMessageReference `json:"message_reference,omitempty"`
+}
+
+const DiscordComponentInteraction = 3
+
+type DiscordInteractionData struct {
+	CustomID string `json:"custom_id"`
+}
+
+type DiscordMember struct {
+	User DiscordUser `json:"user"`
+}
+
+type DiscordInteraction struct {
+	ID        string                 `json:"id"`
+	Token     string                 `json:"token"`
+	Type      int                    `json:"type"`
+	ChannelID string                 `json:"channel_id"`
+	Member    *DiscordMember         `json:"member"`
+	User      *DiscordUser           `json:"user"`
+	Message   *DiscordMessage        `json:"message"`
+	Data      DiscordInteractionData `json:"data"`
+}
@@ -1,6 +1,41 @@
 package model
 
+import "time"
+
 type GithubFile struct {
 	LocalPath  string
 	RemotePath string
 }
+
+type GithubPullRequest struct {
+	Repository string
+	Number     int
+	HTMLURL    string
+	NodeID     string
+	HeadBranch string
+	HeadSHA    string
+}
+
+type GithubBranch struct {
+	Name        string
+	SHA         string
+	CommittedAt time.Time
+}
+
+type GithubChecksStatus struct {
+	State  string
+	Total  int
+	Failed []string
+}
+
+type GithubWebhookEvent struct {
+	Type        string
+	Action      string
+	Repository  string
+	Branch      string
+	Url         string
+	Sender      string
+	Merged      bool
+	ReviewState string
+	Conclusion  string
+}
@@ -0,0 +1,91 @@
+package model
+
+const (
+	MattermostSuccess = "#36a64f"
+	MattermostError   = "#e63939"
+)
+
+// MattermostPost is a post read from the websocket or sent to the API. The
+// props are free form, the bot only writes attachments and reads from_bot.
+type MattermostPost struct {
+	ID        string                 `json:"id,omitempty"`
+	ChannelID string                 `json:"channel_id"`
+	UserID    string                 `json:"user_id,omitempty"`
+	RootID    string                 `json:"root_id,omitempty"`
+	Message   string                 `json:"message"`
+	FileIDs   []string               `json:"file_ids,omitempty"`
+	CreateAt  int64                  `json:"create_at,omitempty"`
+	Props     map[string]interface{} `json:"props,omitempty"`
+}
+
+type MattermostField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+type MattermostIntegration struct {
+	URL     string                 `json:"url"`
+	Context map[string]interface{} `json:"context"`
+}
+
+type MattermostAction struct {
+	ID          string                `json:"id"`
+	Name        string                `json:"name"`
+	Style       string                `json:"style,omitempty"`
+	Integration MattermostIntegration `json:"integration"`
+}
+
+// MattermostAttachment is the Slack compatible attachment of the posts.
+type MattermostAttachment struct {
+	Color    string             `json:"color,omitempty"`
+	Pretext  string             `json:"pretext,omitempty"`
+	Title    string             `json:"title,omitempty"`
+	Text     string             `json:"text,omitempty"`
+	Fields   []MattermostField  `json:"fields,omitempty"`
+	ImageURL string             `json:"image_url,omitempty"`
+	Footer   string             `json:"footer,omitempty"`
+	Actions  []MattermostAction `json:"actions,omitempty"`
+}
+
+type MattermostFileInfo struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Extension string `json:"extension"`
+	Size      int64  `json:"size"`
+}
+
+type MattermostUser struct {
+	ID        string `json:"id"`
+	Username  string `json:"username"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Nickname  string `json:"nickname"`
+}
+
+// MattermostApproval is the summary of an upload posted with the buttons to
+// approve or cancel it.
+type MattermostApproval struct {
+	ChannelID string
+	RootID    string
+	Text      string
+	JobID     string
+}
+
+// MattermostActionRequest is the request Mattermost sends to the integration
+// URL when a button is clicked.
+type MattermostActionRequest struct {
+	UserID    string                 `json:"user_id"`
+	ChannelID string                 `json:"channel_id"`
+	PostID    string                 `json:"post_id"`
+	Context   map[string]interface{} `json:"context"`
+}
+
+// MattermostDecision is a click on an approval button.
+type MattermostDecision struct {
+	UserID    string
+	ChannelID string
+	RootID    string
+	Action    string
+	JobID     string
+}
@@ -0,0 +1,33 @@
+package model
+
+type RoutesConfig struct {
+	Routes []RouteConfig `json:"routes"`
+}
+
+type RouteConfig struct {
+	Name       string            `json:"name"`
+	Channel    string            `json:"channel"`
+	Keyword    string            `json:"keyword"`
+	Owner      string            `json:"owner"`
+	Repository string            `json:"repository"`
+	BaseBranch string            `json:"base_branch"`
+	TargetPath string            `json:"target_path"`
+	PathRules  []PathRuleConfig  `json:"path_rules"`
+	Transforms []string          `json:"transforms"`
+	Reviewers  []string          `json:"reviewers"`
+	AutoMerge  AutoMergeSettings `json:"auto_merge"`
+	Targets    []RouteConfig     `json:"targets"`
+}
+
+type PathRuleConfig struct {
+	Match  string `json:"match"`
+	Target string `json:"target"`
+}
+
+type AutoMergeSettings struct {
+	Mode              string `json:"mode"`
+	Method            string `json:"method"`
+	PollInterval      string `json:"poll_interval"`
+	Timeout           string `json:"timeout"`
+	ChecksGracePeriod string `json:"checks_grace_period"`
+}
@@ -1,5 +1,7 @@
 package model
 
+import "time"
+
 type SlackMessageColor string
 
 const (
@@ -8,16 +10,20 @@ const (
 )
 
 type SlackEvent struct {
-	Token string `json:"token"`
-	Event Event  `json:"event"`
+	Token   string `json:"token"`
+	EventID string `json:"event_id"`
+	Event   Event  `json:"event"`
 }
 
 type Event struct {
 	Type      string      `json:"type"`
+	SubType   string      `json:"subtype"`
 	TimeStamp string      `json:"ts"`
+	ThreadTS  string      `json:"thread_ts"`
 	Text      string      `json:"text"`
 	Channel   string      `json:"channel"`
 	User      string      `json:"user"`
+	BotID     string      `json:"bot_id"`
 	Files     []SlackFile `json:"files"`
 }
 
@@ -31,3 +37,76 @@ type SlackFile struct {
 	Url       string `json:"url_private_download"`
 	Size      int    `json:"size"`
 }
+
+type SlackRichMessage struct {
+	ChannelID string
+	ThreadTS  string
+	Pretext   string
+	Text      string
+	Color     SlackMessageColor
+	Buttons   []SlackButton
+	Files     []SlackFileLine
+	Images    []SlackImage
+	Context   []string
+}
+
+type SlackButton struct {
+	Text string
+	Url  string
+}
+
+type SlackFileLine struct {
+	Path string
+	Size int64
+}
+
+type SlackImage struct {
+	Title string
+	Url   string
+}
+
+const (
+	ApproveActionID = "asset_approve"
+	CancelActionID  = "asset_cancel"
+)
+
+type SlackApproval struct {
+	ChannelID string
+	ThreadTS  string
+	Mention   string
+	JobID     string
+	Files     []SlackFileLine
+	Warnings  []string
+	ExpiresAt time.Time
+}
+
+const (
+	UploadShortcutID     = "asset_upload"
+	UploadFormCallbackID = "asset_upload_form"
+)
+
+// The block IDs of the upload form. Each block has a single element whose
+// action ID is the block ID, and the validation errors are keyed by them.
+const (
+	FormRouteBlockID       = "route"
+	FormCategoryBlockID    = "category"
+	FormTitleBlockID       = "title"
+	FormReviewersBlockID   = "reviewers"
+	FormDescriptionBlockID = "description"
+	FormFilesBlockID       = "files"
+)
+
+type SlackUploadForm struct {
+	Routes     []string
+	Categories []string
+}
+
+type SlackUploadSubmission struct {
+	UserID      string
+	Route       string
+	Category    string
+	Title       string
+	Reviewers   string
+	Description string
+	Files       []SlackFile
+}
@@ -0,0 +1,91 @@
+package model
+
+import "encoding/json"
+
+const (
+	TeamsMessageActivity         = "message"
+	TeamsFileDownloadInfoContent = "application/vnd.microsoft.teams.file.download.info"
+	TeamsAdaptiveCardContent     = "application/vnd.microsoft.card.adaptive"
+)
+
+type TeamsChannelAccount struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+}
+
+type TeamsConversationAccount struct {
+	ID               string `json:"id"`
+	ConversationType string `json:"conversationType,omitempty"`
+}
+
+type TeamsAttachment struct {
+	ContentType string          `json:"contentType"`
+	ContentUrl  string          `json:"contentUrl,omitempty"`
+	Name        string          `json:"name,omitempty"`
+	Content     json.RawMessage `json:"content,omitempty"`
+}
+
+// TeamsFileDownloadInfo is the content of the attachments of the files sent
+// to the bot, with a download URL that needs no token.
+type TeamsFileDownloadInfo struct {
+	DownloadUrl string `json:"downloadUrl"`
+	FileType    string `json:"fileType"`
+}
+
+// TeamsActivity is an activity of the Bot Framework, received on the
+// messaging endpoint or sent to a conversation.
+type TeamsActivity struct {
+	Type         string                    `json:"type"`
+	ID           string                    `json:"id,omitempty"`
+	ServiceUrl   string                    `json:"serviceUrl,omitempty"`
+	From         *TeamsChannelAccount      `json:"from,omitempty"`
+	Conversation *TeamsConversationAccount `json:"conversation,omitempty"`
+	Text         string                    `json:"text,omitempty"`
+	TextFormat   string                    `json:"textFormat,omitempty"`
+	Attachments  []TeamsAttachment         `json:"attachments,omitempty"`
+	ReplyToID    string                    `json:"replyToId,omitempty"`
+	Value        json.RawMessage           `json:"value,omitempty"`
+}
+
+// TeamsCardAction is the data of the buttons of the approval cards, sent
+// back as the value of a message activity.
+type TeamsCardAction struct {
+	Action string `json:"action"`
+	JobID  string `json:"job_id"`
+}
+
+type AdaptiveCard struct {
+	Type    string            `json:"type"`
+	Schema  string            `json:"$schema"`
+	Version string            `json:"version"`
+	Body    []AdaptiveElement `json:"body"`
+	Actions []AdaptiveAction  `json:"actions,omitempty"`
+}
+
+type AdaptiveFact struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+// AdaptiveElement is a TextBlock, an Image or a FactSet of a card.
+type AdaptiveElement struct {
+	Type     string         `json:"type"`
+	Text     string         `json:"text,omitempty"`
+	Weight   string         `json:"weight,omitempty"`
+	Size     string         `json:"size,omitempty"`
+	Color    string         `json:"color,omitempty"`
+	IsSubtle bool           `json:"isSubtle,omitempty"`
+	Wrap     bool           `json:"wrap,omitempty"`
+	Url      string         `json:"url,omitempty"`
+	AltText  string         `json:"altText,omitempty"`
+	Facts    []AdaptiveFact `json:"facts,omitempty"`
+}
+
+// AdaptiveAction is an Action.OpenUrl or an Action.Submit of a card.
+type AdaptiveAction struct {
+	Type  string      `json:"type"`
+	Title string      `json:"title"`
+	Url   string      `json:"url,omitempty"`
+	Style string      `json:"style,omitempty"`
+	Data  interface{} `json:"data,omitempty"`
+}
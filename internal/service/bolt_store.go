@@ -0,0 +1,127 @@
+package service
+
+import (
+	"encoding/json"
+	"go.etcd.io/bbolt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// BoltStore is a KeyValueStore kept in a BoltDB file, where every write is a
+// transaction synced to the disk.
+type BoltStore struct {
+	db *bbolt.DB
+}
+
+// NewBoltStore opens the store, waiting a second at most for another process
+// holding the file.
+func NewBoltStore(path string) (*BoltStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+func (store *BoltStore) Put(bucket, key string, value interface{}) error {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return store.db.Update(func(tx *bbolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(key), content)
+	})
+}
+
+func (store *BoltStore) Get(bucket, key string, value interface{}) (bool, error) {
+	var content []byte
+	err := store.db.View(func(tx *bbolt.Tx) error {
+		if b := tx.Bucket([]byte(bucket)); b != nil {
+			// The value is only valid in the transaction.
+			if stored := b.Get([]byte(key)); stored != nil {
+				content = append([]byte{}, stored...)
+			}
+		}
+		return nil
+	})
+	if err != nil || content == nil {
+		return false, err
+	}
+
+	return true, json.Unmarshal(content, value)
+}
+
+func (store *BoltStore) Delete(bucket, key string) error {
+	return store.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		return b.Delete([]byte(key))
+	})
+}
+
+func (store *BoltStore) Keys(bucket string) ([]string, error) {
+	var keys []string
+	err := store.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(key, _ []byte) error {
+			keys = append(keys, string(key))
+			return nil
+		})
+	})
+	return keys, err
+}
+
+// Import copies the values of a JSON store file in a single transaction and
+// returns how many were copied.
+func (store *BoltStore) Import(jsonPath string) (int, error) {
+	content, err := ioutil.ReadFile(jsonPath)
+	if err != nil {
+		return 0, err
+	}
+
+	buckets := map[string]map[string]json.RawMessage{}
+	if len(content) > 0 {
+		if err = json.Unmarshal(content, &buckets); err != nil {
+			return 0, err
+		}
+	}
+
+	count := 0
+	err = store.db.Update(func(tx *bbolt.Tx) error {
+		for bucket, values := range buckets {
+			b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+			if err != nil {
+				return err
+			}
+			for key, value := range values {
+				if err = b.Put([]byte(key), value); err != nil {
+					return err
+				}
+				count++
+			}
+		}
+		return nil
+	})
+	return count, err
+}
+
+func (store *BoltStore) Close() error {
+	return store.db.Close()
+}
@@ -0,0 +1,384 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/model"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/util/requestutil"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+type DiscordClient interface {
+	StartGateway(ctx context.Context, handlers DiscordHandlers) error
+	PostMessage(ctx context.Context, channelID string, message model.DiscordOutgoingMessage) (string, error)
+	EditMessage(ctx context.Context, channelID, messageID string, message model.DiscordOutgoingMessage) error
+	AddReaction(ctx context.Context, channelID, messageID, emoji string) error
+	RemoveReaction(ctx context.Context, channelID, messageID, emoji string) error
+	AcknowledgeInteraction(ctx context.Context, interaction model.DiscordInteraction) error
+	DownloadFile(ctx context.Context, url, extension string, size, maxSize int64) (string, error)
+}
+
+type DiscordMessageFunction func(context.Context, model.DiscordMessage) error
+
+type DiscordInteractionFunction func(context.Context, model.DiscordInteraction) error
+
+// DiscordHandlers are the functions called for the gateway events. The
+// optional handlers can be nil.
+type DiscordHandlers struct {
+	Messages     DiscordMessageFunction
+	Interactions DiscordInteractionFunction
+}
+
+var (
+	DiscordRequestError = fmt.Errorf("the Discord request failed")
+	DiscordGatewayError = fmt.Errorf("unexpected Discord gateway payload")
+)
+
+const (
+	DefaultDiscordGatewayURL = "wss://gateway.discord.gg/?v=10&encoding=json"
+	DefaultDiscordAPIURL     = "https://discord.com/api/v10"
+)
+
+// The gateway opcodes used by the bot.
+const (
+	opDispatch       = 0
+	opHeartbeat      = 1
+	opIdentify       = 2
+	opReconnect      = 7
+	opInvalidSession = 9
+	opHello          = 10
+	opHeartbeatAck   = 11
+)
+
+// The intents of the guild messages, the direct messages and the content of
+// the messages, which is a privileged intent to enable in the developer
+// portal.
+const discordIntents = 1<<9 | 1<<12 | 1<<15
+
+const (
+	reconnectDelay        = 5 * time.Second
+	deferredUpdateMessage = 6
+)
+
+// The close codes after which the bot can't connect again without a
+// configuration change.
+var fatalCloseCodes = []int{4004, 4010, 4011, 4012, 4013, 4014}
+
+type gatewayPayload struct {
+	Op int             `json:"op"`
+	D  json.RawMessage `json:"d"`
+	S  *int64          `json:"s,omitempty"`
+	T  string          `json:"t,omitempty"`
+}
+
+type DiscordClientImpl struct {
+	token      string
+	gatewayURL string
+	apiURL     string
+	client     *http.Client
+	handling   *sync.WaitGroup
+}
+
+// NewDiscordClient creates a bot client. The URLs can point to a local fake
+// of the gateway and of the API. The events still being processed are
+// counted in handling.
+func NewDiscordClient(token, gatewayURL, apiURL string, handling *sync.WaitGroup) DiscordClient {
+	return &DiscordClientImpl{
+		token:      token,
+		gatewayURL: gatewayURL,
+		apiURL:     strings.TrimSuffix(apiURL, "/"),
+		client:     &http.Client{Timeout: 30 * time.Second},
+		handling:   handling,
+	}
+}
+
+// StartGateway keeps a gateway connection open until the context is done,
+// connecting again when Discord closes it. The session is never resumed, the
+// events sent while the bot was disconnected are lost. It returns once the
+// event being processed is done.
+func (discordClient *DiscordClientImpl) StartGateway(ctx context.Context, handlers DiscordHandlers) error {
+	for {
+		err := discordClient.runGateway(ctx, handlers)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		var closeError *websocket.CloseError
+		if errors.As(err, &closeError) && websocket.IsCloseError(closeError, fatalCloseCodes...) {
+			return err
+		}
+
+		log.Printf("the Discord gateway was disconnected, connecting again: %v\n", err)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+func (discordClient *DiscordClientImpl) runGateway(ctx context.Context, handlers DiscordHandlers) error {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, discordClient.gatewayURL, nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.Close()
+		case <-done:
+		}
+	}()
+
+	var hello gatewayPayload
+	if err = conn.ReadJSON(&hello); err != nil {
+		return err
+	}
+	if hello.Op != opHello {
+		return fmt.Errorf("%w: expected hello, got op %d", DiscordGatewayError, hello.Op)
+	}
+
+	var helloData struct {
+		HeartbeatInterval int64 `json:"heartbeat_interval"`
+	}
+	if err = json.Unmarshal(hello.D, &helloData); err != nil {
+		return err
+	}
+
+	gateway := &gatewayConn{conn: conn}
+	if err = gateway.identify(discordClient.token); err != nil {
+		return err
+	}
+
+	go gateway.heartbeat(time.Duration(helloData.HeartbeatInterval)*time.Millisecond, done)
+
+	for {
+		var payload gatewayPayload
+		if err = conn.ReadJSON(&payload); err != nil {
+			return err
+		}
+		gateway.setSequence(payload.S)
+
+		switch payload.Op {
+		case opDispatch:
+			discordClient.handling.Add(1)
+			dispatch(ctx, payload, handlers)
+			discordClient.handling.Done()
+		case opHeartbeat:
+			if err = gateway.sendHeartbeat(); err != nil {
+				return err
+			}
+		case opReconnect:
+			return fmt.Errorf("%w: Discord asked to reconnect", DiscordGatewayError)
+		case opInvalidSession:
+			return fmt.Errorf("%w: the session is invalid", DiscordGatewayError)
+		case opHeartbeatAck:
+		}
+	}
+}
+
+// dispatch calls the handlers in the reading loop, so the events are
+// processed one at a time as in socket mode.
+func dispatch(ctx context.Context, payload gatewayPayload, handlers DiscordHandlers) {
+	switch payload.T {
+	case "READY":
+		log.Println("connected to the Discord gateway")
+	case "MESSAGE_CREATE":
+		if handlers.Messages == nil {
+			return
+		}
+
+		var message model.DiscordMessage
+		if err := json.Unmarshal(payload.D, &message); err != nil {
+			log.Printf("error to parse the Discord message: %v\n", err)
+			return
+		}
+		if err := handlers.Messages(ctx, message); err != nil {
+			log.Printf("error to process the Discord message: %v\n", err)
+		}
+	case "INTERACTION_CREATE":
+		if handlers.Interactions == nil {
+			return
+		}
+
+		var interaction model.DiscordInteraction
+		if err := json.Unmarshal(payload.D, &interaction); err != nil {
+			log.Printf("error to parse the Discord interaction: %v\n", err)
+			return
+		}
+		if err := handlers.Interactions(ctx, interaction); err != nil {
+			log.Printf("error to process the Discord interaction: %v\n", err)
+		}
+	}
+}
+
+// gatewayConn serializes the writes of the reading loop and of the heartbeat
+// and keeps the last sequence number, sent with every heartbeat.
+type gatewayConn struct {
+	conn     *websocket.Conn
+	mutex    sync.Mutex
+	sequence *int64
+}
+
+func (gateway *gatewayConn) send(op int, data interface{}) error {
+	content, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	gateway.mutex.Lock()
+	defer gateway.mutex.Unlock()
+	return gateway.conn.WriteJSON(gatewayPayload{Op: op, D: content})
+}
+
+func (gateway *gatewayConn) identify(token string) error {
+	return gateway.send(opIdentify, map[string]interface{}{
+		"token":   token,
+		"intents": discordIntents,
+		"properties": map[string]string{
+			"os":      "linux",
+			"browser": "slack-assets-bot",
+			"device":  "slack-assets-bot",
+		},
+	})
+}
+
+func (gateway *gatewayConn) setSequence(sequence *int64) {
+	if sequence == nil {
+		return
+	}
+
+	gateway.mutex.Lock()
+	defer gateway.mutex.Unlock()
+	gateway.sequence = sequence
+}
+
+func (gateway *gatewayConn) sendHeartbeat() error {
+	gateway.mutex.Lock()
+	sequence := gateway.sequence
+	gateway.mutex.Unlock()
+
+	return gateway.send(opHeartbeat, sequence)
+}
+
+func (gateway *gatewayConn) heartbeat(interval time.Duration, done chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := gateway.sendHeartbeat(); err != nil {
+				log.Printf("error to send the Discord heartbeat: %v\n", err)
+				_ = gateway.conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// PostMessage posts the message and returns its ID, which is used to edit it.
+func (discordClient *DiscordClientImpl) PostMessage(ctx context.Context, channelID string, message model.DiscordOutgoingMessage) (string, error) {
+	var posted model.DiscordMessage
+	err := discordClient.request(ctx, http.MethodPost, "/channels/"+channelID+"/messages", outgoing(message), &posted)
+	return posted.ID, err
+}
+
+// EditMessage replaces the content, the embeds and the components of a
+// message, removing the ones that are not given.
+func (discordClient *DiscordClientImpl) EditMessage(ctx context.Context, channelID, messageID string, message model.DiscordOutgoingMessage) error {
+	message.MessageReference = nil
+	return discordClient.request(ctx, http.MethodPatch, "/channels/"+channelID+"/messages/"+messageID, outgoing(message), nil)
+}
+
+func (discordClient *DiscordClientImpl) AddReaction(ctx context.Context, channelID, messageID, emoji string) error {
+	path := "/channels/" + channelID + "/messages/" + messageID + "/reactions/" + url.PathEscape(emoji) + "/@me"
+	return discordClient.request(ctx, http.MethodPut, path, nil, nil)
+}
+
+func (discordClient *DiscordClientImpl) RemoveReaction(ctx context.Context, channelID, messageID, emoji string) error {
+	path := "/channels/" + channelID + "/messages/" + messageID + "/reactions/" + url.PathEscape(emoji) + "/@me"
+	return discordClient.request(ctx, http.MethodDelete, path, nil, nil)
+}
+
+// AcknowledgeInteraction tells Discord the click was received without
+// changing the message, which is edited later.
+func (discordClient *DiscordClientImpl) AcknowledgeInteraction(ctx context.Context, interaction model.DiscordInteraction) error {
+	path := "/interactions/" + interaction.ID + "/" + interaction.Token + "/callback"
+	return discordClient.request(ctx, http.MethodPost, path, map[string]int{"type": deferredUpdateMessage}, nil)
+}
+
+// DownloadFile downloads an attachment. The attachment URLs are signed, they
+// don't need the token of the bot.
+func (discordClient *DiscordClientImpl) DownloadFile(ctx context.Context, url, extension string, size, maxSize int64) (string, error) {
+	return requestutil.DownloadFile(ctx, url, nil, extension, size, maxSize)
+}
+
+func (discordClient *DiscordClientImpl) request(ctx context.Context, method, path string, body, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		content, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(content)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, discordClient.apiURL+path, reader)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", "Bot "+discordClient.token)
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := discordClient.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	content, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+
+	if response.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("%w: %s %s: %d %s", DiscordRequestError, method, path, response.StatusCode, strings.TrimSpace(string(content)))
+	}
+
+	if result == nil || len(content) == 0 {
+		return nil
+	}
+	return json.Unmarshal(content, result)
+}
+
+// outgoing sends empty lists instead of nulls, which Discord refuses.
+func outgoing(message model.DiscordOutgoingMessage) model.DiscordOutgoingMessage {
+	if message.Embeds == nil {
+		message.Embeds = []model.DiscordEmbed{}
+	}
+	if message.Components == nil {
+		message.Components = []model.DiscordComponent{}
+	}
+	return message
+}
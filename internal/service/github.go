@@ -7,12 +7,22 @@ import (
 	"github.com/wallacehenriquesilva/slack-assets-bot/internal/model"
 	"golang.org/x/oauth2"
 	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
 	"time"
 )
 
 type GithubClient interface {
-	CreateCommit(commitBranch, baseBranch, message string, sourceFiles []model.GithubFile) error
-	CreatePullRequest(headBranch, baseBranch, title, description string) (string, error)
+	CreateCommit(ctx context.Context, commitBranch, baseBranch string, reuseBranch bool, message string, sourceFiles []model.GithubFile) (bool, error)
+	CreatePullRequest(ctx context.Context, headBranch, baseBranch, title, description string, reviewers []string) (model.GithubPullRequest, error)
+	FindPullRequest(ctx context.Context, headBranch string) (model.GithubPullRequest, bool, error)
+	ListBranches(ctx context.Context, prefix string) ([]model.GithubBranch, error)
+	DeleteBranch(ctx context.Context, branch string) error
+	EnableAutoMerge(ctx context.Context, nodeID, method string) error
+	GetChecksStatus(ctx context.Context, sha string) (model.GithubChecksStatus, error)
+	MergePullRequest(ctx context.Context, number int, sha, method string) error
+	FileURL(ctx context.Context, branch, remotePath string) (string, error)
 }
 
 var (
@@ -22,8 +32,17 @@ var (
 	SameBranchError        = fmt.Errorf("the pr base and head branch are the same, it is not allowed")
 	InvalidLocalPathError  = fmt.Errorf("invalid local path. The local path can't be empty")
 	InvalidRemotePathError = fmt.Errorf("invalid remote path. The remote path can't be empty")
+	AutoMergeError         = fmt.Errorf("error to enable the auto-merge")
+	NoDownloadURLError     = fmt.Errorf("the file has no download url")
+	BranchExistsError      = fmt.Errorf("the branch already exists")
 )
 
+const enableAutoMergeMutation = `mutation($id: ID!, $method: PullRequestMergeMethod!) {
+  enablePullRequestAutoMerge(input: {pullRequestId: $id, mergeMethod: $method}) {
+    clientMutationId
+  }
+}`
+
 type GithubClientImpl struct {
 	client      *github.Client
 	owner       string
@@ -50,21 +69,41 @@ func getGithubClient(ctx context.Context, token string) *github.Client {
 
 }
 
-func (githubClient *GithubClientImpl) CreateCommit(commitBranch, baseBranch, message string, sourceFiles []model.GithubFile) error {
-	ctx := context.Background()
-
-	ref, err := githubClient.getRef(ctx, githubClient.client, commitBranch, baseBranch)
+// CreateCommit commits the files to the commit branch, creating it from the
+// base branch when it doesn't exist, and tells whether the branch was created.
+// An existing branch is only committed to when reuseBranch is set, it may be
+// the branch of another upload. A branch created here is deleted again when
+// the commit fails.
+func (githubClient *GithubClientImpl) CreateCommit(ctx context.Context, commitBranch, baseBranch string, reuseBranch bool, message string, sourceFiles []model.GithubFile) (bool, error) {
+	ref, created, err := githubClient.getRef(ctx, githubClient.client, commitBranch, baseBranch, reuseBranch)
 	if err != nil {
-		return err
+		return false, err
+	}
+
+	if err = githubClient.commit(ctx, ref, message, sourceFiles); err != nil {
+		if created {
+			if deleteErr := githubClient.DeleteBranch(ctx, commitBranch); deleteErr != nil {
+				log.Printf("error to delete the branch %s after the failed commit: %v\n", commitBranch, deleteErr)
+			} else {
+				created = false
+			}
+		}
+		return created, err
 	}
 
+	return created, nil
+}
+
+// commit creates a commit with the files on top of the ref and moves the ref
+// to it.
+func (githubClient *GithubClientImpl) commit(ctx context.Context, ref *github.Reference, message string, sourceFiles []model.GithubFile) error {
 	tree, err := githubClient.getTree(ctx, githubClient.client, ref, sourceFiles)
 	if err != nil {
 		return err
 	}
 
 	parent, _, err := githubClient.client.Repositories.GetCommit(
-		context.Background(),
+		ctx,
 		githubClient.owner,
 		githubClient.repository,
 		*ref.Object.SHA,
@@ -88,13 +127,13 @@ func (githubClient *GithubClientImpl) CreateCommit(commitBranch, baseBranch, mes
 	return err
 }
 
-func (githubClient *GithubClientImpl) CreatePullRequest(headBranch, baseBranch, title, description string) (string, error) {
+func (githubClient *GithubClientImpl) CreatePullRequest(ctx context.Context, headBranch, baseBranch, title, description string, reviewers []string) (model.GithubPullRequest, error) {
 	if title == "" {
-		return "", InvalidPrTitleError
+		return model.GithubPullRequest{}, InvalidPrTitleError
 	}
 
 	if headBranch == "" {
-		return "", InvalidHeadBranchError
+		return model.GithubPullRequest{}, InvalidHeadBranchError
 	}
 
 	commitBranch := fmt.Sprintf("%s:%s", githubClient.owner, headBranch)
@@ -107,36 +146,280 @@ func (githubClient *GithubClientImpl) CreatePullRequest(headBranch, baseBranch,
 		MaintainerCanModify: github.Bool(true),
 	}
 
-	pullRequest, _, err := githubClient.client.PullRequests.Create(context.Background(), githubClient.owner, githubClient.repository, pullRequestPayload)
+	pullRequest, _, err := githubClient.client.PullRequests.Create(ctx, githubClient.owner, githubClient.repository, pullRequestPayload)
+	if err != nil {
+		return model.GithubPullRequest{}, err
+	}
+
+	if len(reviewers) > 0 {
+		err = githubClient.requestReviewers(ctx, pullRequest.GetNumber(), reviewers)
+		if err != nil {
+			log.Printf("error to request reviewers %v on %s: %v\n", reviewers, pullRequest.GetHTMLURL(), err)
+		}
+	}
+
+	return githubClient.pullRequestOf(pullRequest, headBranch), nil
+}
+
+// FindPullRequest returns the open pull request of the branch, if any.
+func (githubClient *GithubClientImpl) FindPullRequest(ctx context.Context, headBranch string) (model.GithubPullRequest, bool, error) {
+	options := &github.PullRequestListOptions{
+		State: "open",
+		Head:  fmt.Sprintf("%s:%s", githubClient.owner, headBranch),
+	}
+
+	pullRequests, _, err := githubClient.client.PullRequests.List(ctx, githubClient.owner, githubClient.repository, options)
+	if err != nil || len(pullRequests) == 0 {
+		return model.GithubPullRequest{}, false, err
+	}
+
+	return githubClient.pullRequestOf(pullRequests[0], headBranch), true, nil
+}
+
+func (githubClient *GithubClientImpl) pullRequestOf(pullRequest *github.PullRequest, headBranch string) model.GithubPullRequest {
+	return model.GithubPullRequest{
+		Repository: githubClient.owner + "/" + githubClient.repository,
+		Number:     pullRequest.GetNumber(),
+		HTMLURL:    pullRequest.GetHTMLURL(),
+		NodeID:     pullRequest.GetNodeID(),
+		HeadBranch: headBranch,
+		HeadSHA:    pullRequest.GetHead().GetSHA(),
+	}
+}
+
+// EnableAutoMerge turns on the GitHub auto-merge of a pull request, which is
+// only available through the GraphQL API.
+func (githubClient *GithubClientImpl) EnableAutoMerge(ctx context.Context, nodeID, method string) error {
+	payload := map[string]interface{}{
+		"query": enableAutoMergeMutation,
+		"variables": map[string]string{
+			"id":     nodeID,
+			"method": strings.ToUpper(method),
+		},
+	}
+
+	request, err := githubClient.client.NewRequest(http.MethodPost, "graphql", payload)
+	if err != nil {
+		return err
+	}
+
+	var response struct {
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if _, err = githubClient.client.Do(ctx, request, &response); err != nil {
+		return err
+	}
+
+	if len(response.Errors) > 0 {
+		return fmt.Errorf("%w: %s", AutoMergeError, response.Errors[0].Message)
+	}
+
+	return nil
+}
+
+// GetChecksStatus combines the commit statuses and the check runs of a commit,
+// reading every page of them, into a single state. Any failure wins over
+// pending, and pending wins over success.
+func (githubClient *GithubClientImpl) GetChecksStatus(ctx context.Context, sha string) (model.GithubChecksStatus, error) {
+	status := model.GithubChecksStatus{State: "success"}
+
+	statusOptions := &github.ListOptions{PerPage: 100}
+	for {
+		combined, response, err := githubClient.client.Repositories.GetCombinedStatus(ctx, githubClient.owner,
+			githubClient.repository, sha, statusOptions)
+		if err != nil {
+			return status, err
+		}
+
+		for _, repoStatus := range combined.Statuses {
+			status.Total++
+			switch repoStatus.GetState() {
+			case "success":
+			case "pending":
+				markPending(&status)
+			default:
+				markFailed(&status, repoStatus.GetContext())
+			}
+		}
+
+		if response.NextPage == 0 {
+			break
+		}
+		statusOptions.Page = response.NextPage
+	}
+
+	checkRunOptions := &github.ListCheckRunsOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		checkRuns, response, err := githubClient.client.Checks.ListCheckRunsForRef(ctx, githubClient.owner,
+			githubClient.repository, sha, checkRunOptions)
+		if err != nil {
+			return status, err
+		}
+
+		for _, checkRun := range checkRuns.CheckRuns {
+			status.Total++
+			if checkRun.GetStatus() != "completed" {
+				markPending(&status)
+				continue
+			}
+
+			switch checkRun.GetConclusion() {
+			case "success", "neutral", "skipped":
+			default:
+				markFailed(&status, checkRun.GetName())
+			}
+		}
+
+		if response.NextPage == 0 {
+			break
+		}
+		checkRunOptions.Page = response.NextPage
+	}
+
+	return status, nil
+}
+
+func (githubClient *GithubClientImpl) MergePullRequest(ctx context.Context, number int, sha, method string) error {
+	options := &github.PullRequestOptions{
+		SHA:         sha,
+		MergeMethod: method,
+	}
+
+	_, _, err := githubClient.client.PullRequests.Merge(ctx, githubClient.owner, githubClient.repository, number, "", options)
+	return err
+}
+
+// FileURL returns the download URL GitHub gives for a file of the branch,
+// with the path escaped. The URL of a file in a private repository carries a
+// token that expires, so it is only meant to be fetched right away, as the
+// chats do with the images of a message.
+func (githubClient *GithubClientImpl) FileURL(ctx context.Context, branch, remotePath string) (string, error) {
+	options := &github.RepositoryContentGetOptions{Ref: branch}
+	file, _, _, err := githubClient.client.Repositories.GetContents(ctx, githubClient.owner, githubClient.repository, remotePath, options)
 	if err != nil {
 		return "", err
 	}
 
-	return pullRequest.GetHTMLURL(), nil
+	if file.GetDownloadURL() == "" {
+		return "", fmt.Errorf("%w: %s", NoDownloadURLError, remotePath)
+	}
+	return file.GetDownloadURL(), nil
+}
+
+func markPending(status *model.GithubChecksStatus) {
+	if status.State != "failure" {
+		status.State = "pending"
+	}
+}
+
+func markFailed(status *model.GithubChecksStatus, name string) {
+	status.State = "failure"
+	status.Failed = append(status.Failed, name)
 }
 
-// getRef returns the commit branch reference object if it exists or creates it
-// from the base branch before returning it.
-func (githubClient *GithubClientImpl) getRef(ctx context.Context, client *github.Client, commitBranch, baseBranch string) (ref *github.Reference, err error) {
-	if ref, _, err = client.Git.GetRef(ctx, githubClient.owner, githubClient.repository, "refs/heads/"+commitBranch); err == nil {
-		return ref, nil
+// requestReviewers asks the given users for a review. Reviewers written as
+// "org/team" are requested as teams.
+func (githubClient *GithubClientImpl) requestReviewers(ctx context.Context, number int, reviewers []string) error {
+	var request github.ReviewersRequest
+	for _, reviewer := range reviewers {
+		if index := strings.Index(reviewer, "/"); index >= 0 {
+			request.TeamReviewers = append(request.TeamReviewers, reviewer[index+1:])
+		} else {
+			request.Reviewers = append(request.Reviewers, reviewer)
+		}
+	}
+
+	_, _, err := githubClient.client.PullRequests.RequestReviewers(ctx, githubClient.owner, githubClient.repository, number, request)
+	return err
+}
+
+// getRef returns the commit branch reference object if it exists and can be
+// reused, or creates it from the base branch before returning it, telling
+// whether it was created. Only a missing branch is created, any other error
+// of GitHub is returned.
+func (githubClient *GithubClientImpl) getRef(ctx context.Context, client *github.Client, commitBranch, baseBranch string, reuseBranch bool) (ref *github.Reference, created bool, err error) {
+	ref, response, err := client.Git.GetRef(ctx, githubClient.owner, githubClient.repository, "refs/heads/"+commitBranch)
+	switch {
+	case err == nil && reuseBranch:
+		return ref, false, nil
+	case err == nil:
+		return nil, false, fmt.Errorf("%w: %s", BranchExistsError, commitBranch)
+	case response == nil:
+		return nil, false, err
+	case response.StatusCode == http.StatusNotFound:
+	case response.StatusCode == http.StatusOK:
+		// GitHub lists the branches starting with the name when none has it
+		// exactly.
+	default:
+		return nil, false, err
 	}
 
 	if commitBranch == baseBranch {
-		return nil, SameBranchError
+		return nil, false, SameBranchError
 	}
 
 	if baseBranch == "" {
-		return nil, InvalidBaseBranchError
+		return nil, false, InvalidBaseBranchError
 	}
 
 	var baseRef *github.Reference
 	if baseRef, _, err = client.Git.GetRef(ctx, githubClient.owner, githubClient.repository, "refs/heads/"+baseBranch); err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	newRef := &github.Reference{Ref: github.String("refs/heads/" + commitBranch), Object: &github.GitObject{SHA: baseRef.Object.SHA}}
-	ref, _, err = client.Git.CreateRef(ctx, githubClient.owner, githubClient.repository, newRef)
-	return ref, err
+	if ref, _, err = client.Git.CreateRef(ctx, githubClient.owner, githubClient.repository, newRef); err != nil {
+		return nil, false, err
+	}
+	return ref, true, nil
+}
+
+// ListBranches returns the branches whose name starts with the prefix, with
+// the date of their last commit.
+func (githubClient *GithubClientImpl) ListBranches(ctx context.Context, prefix string) ([]model.GithubBranch, error) {
+	options := &github.ReferenceListOptions{Type: "heads", ListOptions: github.ListOptions{PerPage: 100}}
+
+	var branches []model.GithubBranch
+	for {
+		refs, response, err := githubClient.client.Git.ListRefs(ctx, githubClient.owner, githubClient.repository, options)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, ref := range refs {
+			name := strings.TrimPrefix(ref.GetRef(), "refs/heads/")
+			if !strings.HasPrefix(name, prefix) {
+				continue
+			}
+
+			commit, _, err := githubClient.client.Git.GetCommit(ctx, githubClient.owner, githubClient.repository, ref.GetObject().GetSHA())
+			if err != nil {
+				return nil, err
+			}
+
+			branches = append(branches, model.GithubBranch{
+				Name:        name,
+				SHA:         ref.GetObject().GetSHA(),
+				CommittedAt: commit.GetCommitter().GetDate(),
+			})
+		}
+
+		if response.NextPage == 0 {
+			return branches, nil
+		}
+		options.Page = response.NextPage
+	}
+}
+
+// DeleteBranch deletes the branch, a branch that is already gone is not an
+// error.
+func (githubClient *GithubClientImpl) DeleteBranch(ctx context.Context, branch string) error {
+	response, err := githubClient.client.Git.DeleteRef(ctx, githubClient.owner, githubClient.repository, "heads/"+branch)
+	if err != nil && response != nil && response.StatusCode == http.StatusUnprocessableEntity {
+		return nil
+	}
+	return err
 }
 
 // getTree generates the tree to commit based on the given files and the commit
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/google/go-github/github"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/model"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+)
+
+type GithubWebhook interface {
+	Handler(processFunction WebhookProcessFunction) http.Handler
+}
+
+type WebhookProcessFunction func(context.Context, model.GithubWebhookEvent) error
+
+var (
+	MissingSignatureError = fmt.Errorf("the webhook signature is missing")
+	InvalidSignatureError = fmt.Errorf("the webhook signature is invalid")
+)
+
+const (
+	signatureHeader = "X-Hub-Signature-256"
+	eventTypeHeader = "X-GitHub-Event"
+	maxWebhookSize  = 5 << 20
+)
+
+type GithubWebhookImpl struct {
+	secret []byte
+}
+
+func NewGithubWebhook(secret string) GithubWebhook {
+	return &GithubWebhookImpl{
+		secret: []byte(secret),
+	}
+}
+
+// Handler verifies the signature of the deliveries and forwards the
+// pull_request, pull_request_review and check_suite events to the given
+// function. Every other event is acknowledged and ignored.
+func (githubWebhook *GithubWebhookImpl) Handler(processFunction WebhookProcessFunction) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method != http.MethodPost {
+			writer.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		payload, err := ioutil.ReadAll(http.MaxBytesReader(writer, request.Body, maxWebhookSize))
+		if err != nil {
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if err = githubWebhook.verifySignature(request.Header.Get(signatureHeader), payload); err != nil {
+			log.Printf("error to verify the webhook delivery: %v\n", err)
+			writer.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		event, ok, err := parseWebhookEvent(request.Header.Get(eventTypeHeader), payload)
+		if err != nil {
+			log.Printf("error to parse the webhook delivery: %v\n", err)
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		writer.WriteHeader(http.StatusNoContent)
+
+		if !ok {
+			return
+		}
+
+		if err = processFunction(request.Context(), event); err != nil {
+			log.Printf("error to process webhook event: %v\n", err)
+		}
+	})
+}
+
+func (githubWebhook *GithubWebhookImpl) verifySignature(signature string, payload []byte) error {
+	if !strings.HasPrefix(signature, "sha256=") {
+		return MissingSignatureError
+	}
+
+	expected, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return InvalidSignatureError
+	}
+
+	mac := hmac.New(sha256.New, githubWebhook.secret)
+	mac.Write(payload)
+	if !hmac.Equal(mac.Sum(nil), expected) {
+		return InvalidSignatureError
+	}
+
+	return nil
+}
+
+func parseWebhookEvent(eventType string, payload []byte) (model.GithubWebhookEvent, bool, error) {
+	switch eventType {
+	case "pull_request", "pull_request_review", "check_suite":
+	default:
+		return model.GithubWebhookEvent{}, false, nil
+	}
+
+	parsed, err := github.ParseWebHook(eventType, payload)
+	if err != nil {
+		return model.GithubWebhookEvent{}, false, err
+	}
+
+	event := model.GithubWebhookEvent{Type: eventType}
+
+	switch parsed := parsed.(type) {
+	case *github.PullRequestEvent:
+		event.Action = parsed.GetAction()
+		event.Repository = parsed.GetRepo().GetFullName()
+		event.Branch = parsed.GetPullRequest().GetHead().GetRef()
+		event.Url = parsed.GetPullRequest().GetHTMLURL()
+		event.Sender = parsed.GetSender().GetLogin()
+		event.Merged = parsed.GetPullRequest().GetMerged()
+	case *github.PullRequestReviewEvent:
+		event.Action = parsed.GetAction()
+		event.Repository = parsed.GetRepo().GetFullName()
+		event.Branch = parsed.GetPullRequest().GetHead().GetRef()
+		event.Url = parsed.GetPullRequest().GetHTMLURL()
+		event.Sender = parsed.GetSender().GetLogin()
+		event.ReviewState = strings.ToLower(parsed.GetReview().GetState())
+	case *github.CheckSuiteEvent:
+		event.Action = parsed.GetAction()
+		event.Repository = parsed.GetRepo().GetFullName()
+		event.Branch = parsed.GetCheckSuite().GetHeadBranch()
+		event.Sender = parsed.GetSender().GetLogin()
+		event.Conclusion = parsed.GetCheckSuite().GetConclusion()
+	}
+
+	return event, true, nil
+}
@@ -0,0 +1,370 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gofrs/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/model"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/util/requestutil"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+type MattermostClient interface {
+	StartWebsocket(ctx context.Context, handler MattermostPostFunction) error
+	ActionsHandler(ctx context.Context, handler MattermostDecisionFunction) http.Handler
+	CreatePost(ctx context.Context, post model.MattermostPost) (string, error)
+	UpdatePost(ctx context.Context, postID string, post model.MattermostPost) error
+	SendEphemeral(ctx context.Context, userID string, post model.MattermostPost) error
+	PostApproval(ctx context.Context, approval model.MattermostApproval) (string, error)
+	AddReaction(ctx context.Context, postID, emoji string) error
+	RemoveReaction(ctx context.Context, postID, emoji string) error
+	GetFileInfo(ctx context.Context, fileID string) (model.MattermostFileInfo, error)
+	GetUser(ctx context.Context, userID string) (model.MattermostUser, error)
+	FileURL(fileID string) string
+	DownloadFile(ctx context.Context, url, extension string, size, maxSize int64) (string, error)
+}
+
+type MattermostPostFunction func(context.Context, model.MattermostPost) error
+
+type MattermostDecisionFunction func(context.Context, model.MattermostDecision) error
+
+var MattermostRequestError = fmt.Errorf("the Mattermost request failed")
+
+const (
+	MattermostActionsPath   = "/mattermost/actions"
+	maxMattermostActionSize = 1 << 20
+)
+
+type mattermostEvent struct {
+	Event string `json:"event"`
+	Data  struct {
+		Post string `json:"post"`
+	} `json:"data"`
+}
+
+type MattermostClientImpl struct {
+	token      string
+	serverURL  string
+	actionsURL string
+	secret     string
+	client     *http.Client
+	mutex      sync.Mutex
+	botUserID  string
+	handling   *sync.WaitGroup
+}
+
+// NewMattermostClient creates a bot client for the server. The buttons of the
+// approvals call actionsURL, the public URL of the ActionsHandler, with a
+// secret generated at startup. The posts and the clicks still being processed
+// are counted in handling.
+func NewMattermostClient(token, serverURL, actionsURL string, handling *sync.WaitGroup) (MattermostClient, error) {
+	secret, err := uuid.NewV4()
+	if err != nil {
+		return nil, err
+	}
+
+	return &MattermostClientImpl{
+		token:      token,
+		serverURL:  strings.TrimSuffix(serverURL, "/"),
+		actionsURL: actionsURL,
+		secret:     secret.String(),
+		client:     &http.Client{Timeout: 30 * time.Second},
+		handling:   handling,
+	}, nil
+}
+
+// StartWebsocket listens for the new posts until the context is done,
+// connecting again when the connection drops. The posts of the bot are
+// ignored. It returns once the post being processed is done.
+func (mattermostClient *MattermostClientImpl) StartWebsocket(ctx context.Context, handler MattermostPostFunction) error {
+	botUserID, err := mattermostClient.getBotUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	for {
+		err := mattermostClient.runWebsocket(ctx, botUserID, handler)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		log.Printf("the Mattermost websocket was disconnected, connecting again: %v\n", err)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+func (mattermostClient *MattermostClientImpl) runWebsocket(ctx context.Context, botUserID string, handler MattermostPostFunction) error {
+	websocketURL := "ws" + strings.TrimPrefix(mattermostClient.serverURL, "http") + "/api/v4/websocket"
+	header := http.Header{"Authorization": []string{"Bearer " + mattermostClient.token}}
+
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, websocketURL, header)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.Close()
+		case <-done:
+		}
+	}()
+
+	for {
+		var event mattermostEvent
+		if err = conn.ReadJSON(&event); err != nil {
+			return err
+		}
+
+		if event.Event != "posted" {
+			continue
+		}
+
+		var post model.MattermostPost
+		if err = json.Unmarshal([]byte(event.Data.Post), &post); err != nil {
+			log.Printf("error to parse the Mattermost post: %v\n", err)
+			continue
+		}
+
+		if post.UserID == botUserID || post.Props["from_bot"] == "true" {
+			continue
+		}
+
+		mattermostClient.handling.Add(1)
+		if err = handler(ctx, post); err != nil {
+			log.Printf("error to process the Mattermost post: %v\n", err)
+		}
+		mattermostClient.handling.Done()
+	}
+}
+
+// ActionsHandler receives the clicks on the approval buttons. It answers
+// right away and leaves the decision to the handler, which updates the post
+// with the given context since the one of the request ends with the answer.
+func (mattermostClient *MattermostClientImpl) ActionsHandler(ctx context.Context, handler MattermostDecisionFunction) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method != http.MethodPost {
+			writer.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		var actionRequest model.MattermostActionRequest
+		err := json.NewDecoder(io.LimitReader(request.Body, maxMattermostActionSize)).Decode(&actionRequest)
+		if err != nil {
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if contextValue(actionRequest.Context, "secret") != mattermostClient.secret {
+			writer.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		writer.Header().Set("Content-Type", "application/json")
+		_, _ = writer.Write([]byte("{}"))
+
+		decision := model.MattermostDecision{
+			UserID:    actionRequest.UserID,
+			ChannelID: actionRequest.ChannelID,
+			RootID:    contextValue(actionRequest.Context, "root_id"),
+			Action:    contextValue(actionRequest.Context, "action"),
+			JobID:     contextValue(actionRequest.Context, "job_id"),
+		}
+
+		mattermostClient.handling.Add(1)
+		go func() {
+			defer mattermostClient.handling.Done()
+			if err := handler(ctx, decision); err != nil {
+				log.Printf("error to process the Mattermost action: %v\n", err)
+			}
+		}()
+	})
+}
+
+func contextValue(values map[string]interface{}, key string) string {
+	value, _ := values[key].(string)
+	return value
+}
+
+// CreatePost creates the post and returns its ID, which is used to update it.
+func (mattermostClient *MattermostClientImpl) CreatePost(ctx context.Context, post model.MattermostPost) (string, error) {
+	var created model.MattermostPost
+	err := mattermostClient.request(ctx, http.MethodPost, "/posts", post, &created)
+	return created.ID, err
+}
+
+// UpdatePost replaces the message and the props of the post, removing the
+// attachments when there are none.
+func (mattermostClient *MattermostClientImpl) UpdatePost(ctx context.Context, postID string, post model.MattermostPost) error {
+	patch := map[string]interface{}{
+		"message": post.Message,
+		"props":   post.Props,
+	}
+	if post.Props == nil {
+		patch["props"] = map[string]interface{}{}
+	}
+	return mattermostClient.request(ctx, http.MethodPut, "/posts/"+postID+"/patch", patch, nil)
+}
+
+func (mattermostClient *MattermostClientImpl) SendEphemeral(ctx context.Context, userID string, post model.MattermostPost) error {
+	return mattermostClient.request(ctx, http.MethodPost, "/posts/ephemeral", map[string]interface{}{
+		"user_id": userID,
+		"post":    post,
+	}, nil)
+}
+
+// PostApproval posts the summary with the buttons to approve or cancel the
+// upload. The context of the buttons carries the job and the secret.
+func (mattermostClient *MattermostClientImpl) PostApproval(ctx context.Context, approval model.MattermostApproval) (string, error) {
+	action := func(id, name, style, action string) model.MattermostAction {
+		return model.MattermostAction{
+			ID:    id,
+			Name:  name,
+			Style: style,
+			Integration: model.MattermostIntegration{
+				URL: mattermostClient.actionsURL,
+				Context: map[string]interface{}{
+					"action":  action,
+					"job_id":  approval.JobID,
+					"root_id": approval.RootID,
+					"secret":  mattermostClient.secret,
+				},
+			},
+		}
+	}
+
+	return mattermostClient.CreatePost(ctx, model.MattermostPost{
+		ChannelID: approval.ChannelID,
+		RootID:    approval.RootID,
+		Props: map[string]interface{}{
+			"attachments": []model.MattermostAttachment{{
+				Text: approval.Text,
+				Actions: []model.MattermostAction{
+					action("approve", "Approve", "primary", model.ApproveActionID),
+					action("cancel", "Cancel", "danger", model.CancelActionID),
+				},
+			}},
+		},
+	})
+}
+
+func (mattermostClient *MattermostClientImpl) AddReaction(ctx context.Context, postID, emoji string) error {
+	botUserID, err := mattermostClient.getBotUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	return mattermostClient.request(ctx, http.MethodPost, "/reactions", map[string]string{
+		"user_id":    botUserID,
+		"post_id":    postID,
+		"emoji_name": emoji,
+	}, nil)
+}
+
+func (mattermostClient *MattermostClientImpl) RemoveReaction(ctx context.Context, postID, emoji string) error {
+	botUserID, err := mattermostClient.getBotUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	path := "/users/" + botUserID + "/posts/" + postID + "/reactions/" + url.PathEscape(emoji)
+	return mattermostClient.request(ctx, http.MethodDelete, path, nil, nil)
+}
+
+func (mattermostClient *MattermostClientImpl) GetFileInfo(ctx context.Context, fileID string) (model.MattermostFileInfo, error) {
+	var info model.MattermostFileInfo
+	err := mattermostClient.request(ctx, http.MethodGet, "/files/"+fileID+"/info", nil, &info)
+	return info, err
+}
+
+func (mattermostClient *MattermostClientImpl) GetUser(ctx context.Context, userID string) (model.MattermostUser, error) {
+	var user model.MattermostUser
+	err := mattermostClient.request(ctx, http.MethodGet, "/users/"+userID, nil, &user)
+	return user, err
+}
+
+func (mattermostClient *MattermostClientImpl) FileURL(fileID string) string {
+	return mattermostClient.serverURL + "/api/v4/files/" + fileID
+}
+
+func (mattermostClient *MattermostClientImpl) DownloadFile(ctx context.Context, url, extension string, size, maxSize int64) (string, error) {
+	headers := map[string]string{
+		"Authorization": "Bearer " + mattermostClient.token,
+	}
+	return requestutil.DownloadFile(ctx, url, headers, extension, size, maxSize)
+}
+
+// getBotUserID returns the user of the token, read once.
+func (mattermostClient *MattermostClientImpl) getBotUserID(ctx context.Context) (string, error) {
+	mattermostClient.mutex.Lock()
+	defer mattermostClient.mutex.Unlock()
+
+	if mattermostClient.botUserID != "" {
+		return mattermostClient.botUserID, nil
+	}
+
+	var user model.MattermostUser
+	if err := mattermostClient.request(ctx, http.MethodGet, "/users/me", nil, &user); err != nil {
+		return "", err
+	}
+	mattermostClient.botUserID = user.ID
+	return user.ID, nil
+}
+
+func (mattermostClient *MattermostClientImpl) request(ctx context.Context, method, path string, body, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		content, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(content)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, mattermostClient.serverURL+"/api/v4"+path, reader)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", "Bearer "+mattermostClient.token)
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := mattermostClient.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	content, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+
+	if response.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("%w: %s %s: %d %s", MattermostRequestError, method, path, response.StatusCode, strings.TrimSpace(string(content)))
+	}
+
+	if result == nil || len(content) == 0 {
+		return nil
+	}
+	return json.Unmarshal(content, result)
+}
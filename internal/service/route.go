@@ -0,0 +1,22 @@
+package service
+
+import (
+	"encoding/json"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/model"
+	"io/ioutil"
+)
+
+// LoadRoutes reads the routing table from a JSON file.
+func LoadRoutes(path string) ([]model.RouteConfig, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var config model.RoutesConfig
+	if err = json.Unmarshal(content, &config); err != nil {
+		return nil, err
+	}
+
+	return config.Routes, nil
+}
@@ -2,23 +2,110 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"github.com/slack-go/slack"
 	"github.com/slack-go/slack/slackevents"
 	"github.com/slack-go/slack/socketmode"
 	"github.com/wallacehenriquesilva/slack-assets-bot/internal/model"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/util/fileutil"
 	"github.com/wallacehenriquesilva/slack-assets-bot/internal/util/requestutil"
 	"log"
 	"os"
+	"strings"
+	"sync"
 	"time"
 )
 
 type SlackClient interface {
-	StartSocket(ctx context.Context, processFunction ProcessFunction) error
-	PublishMessage(pretext, text string, color model.SlackMessageColor) error
-	DownloadFile(file model.SlackFile) (string, error)
+	StartSocket(ctx context.Context, handlers SlackHandlers, handling *sync.WaitGroup) error
+	PublishRichMessage(ctx context.Context, message model.SlackRichMessage) error
+	DownloadFile(ctx context.Context, file model.SlackFile, maxSize int64) (string, error)
+	GetUserName(ctx context.Context, userID string) (string, error)
+	GetBotUserID(ctx context.Context) (string, error)
+	AddReaction(ctx context.Context, channelID, timestamp, reaction string) error
+	RemoveReaction(ctx context.Context, channelID, timestamp, reaction string) error
+	PostText(ctx context.Context, channelID, threadTS, text string) (string, error)
+	UpdateText(ctx context.Context, channelID, timestamp, text string) error
+	PostEphemeral(ctx context.Context, channelID, threadTS, userID, text string) error
+	PostApproval(ctx context.Context, approval model.SlackApproval) (string, error)
+	CloseApproval(ctx context.Context, channelID, timestamp, text string) error
+	OpenUploadForm(ctx context.Context, triggerID string, form model.SlackUploadForm) error
 }
 
-type ProcessFunction func(slackevents.EventsAPIEvent) error
+type ProcessFunction func(context.Context, slackevents.EventsAPIEvent) error
+
+type InteractionFunction func(context.Context, slack.InteractionCallback) error
+
+// CommandFunction handles a slash command and returns the reply, which only
+// the user who ran the command sees.
+type CommandFunction func(context.Context, slack.SlashCommand) (string, error)
+
+// SlackHandlers are the functions called for each kind of Slack event, in
+// socket mode or over HTTP. The optional handlers can be nil. The context
+// they get is done when the bot stops.
+type SlackHandlers struct {
+	Events       ProcessFunction
+	Interactions InteractionFunction
+	Commands     CommandFunction
+	Submissions  SubmissionFunction
+}
+
+func (handlers SlackHandlers) event(ctx context.Context, event slackevents.EventsAPIEvent) {
+	if err := handlers.Events(ctx, event); err != nil {
+		log.Printf("error to process event: %v\n", err)
+	}
+}
+
+func (handlers SlackHandlers) interaction(ctx context.Context, callback slack.InteractionCallback) {
+	if handlers.Interactions == nil {
+		return
+	}
+
+	if err := handlers.Interactions(ctx, callback); err != nil {
+		log.Printf("error to process interaction: %v\n", err)
+	}
+}
+
+// isSubmission tells if the interaction is a submitted upload form, which is
+// answered in the acknowledgement.
+func (handlers SlackHandlers) isSubmission(callback slack.InteractionCallback) bool {
+	return callback.Type == slack.InteractionTypeViewSubmission &&
+		callback.View.CallbackID == model.UploadFormCallbackID && handlers.Submissions != nil
+}
+
+func (handlers SlackHandlers) submission(ctx context.Context, callback slack.InteractionCallback, payload json.RawMessage) interface{} {
+	return submitUploadForm(ctx, handlers.Submissions, callback, payload)
+}
+
+// command returns the payload of the acknowledgement of a slash command,
+// which is nil when there is nothing to reply.
+func (handlers SlackHandlers) command(ctx context.Context, command slack.SlashCommand) interface{} {
+	if handlers.Commands == nil {
+		return nil
+	}
+
+	reply, err := handlers.Commands(ctx, command)
+	if err != nil {
+		log.Printf("error to process command %s %s: %v\n", command.Command, command.Text, err)
+		reply = ":warning: " + err.Error()
+	}
+
+	if reply == "" {
+		return nil
+	}
+
+	return map[string]interface{}{
+		"response_type": slack.ResponseTypeEphemeral,
+		"text":          reply,
+	}
+}
+
+const (
+	maxFileLines   = 20
+	maxButtons     = 5
+	maxSectionText = 2900
+)
 
 type SlackClientImpl struct {
 	authToken string
@@ -36,72 +123,312 @@ func NewSlackClient(authToken, appToken, channelID string) SlackClient {
 	}
 }
 
-func (slackClient *SlackClientImpl) StartSocket(ctx context.Context, processFunction ProcessFunction) error {
+// StartSocket receives the events over socket mode until the context is
+// done. The events are processed one at a time, counted in handling, and it
+// returns once the one being processed is done.
+func (slackClient *SlackClientImpl) StartSocket(ctx context.Context, handlers SlackHandlers, handling *sync.WaitGroup) error {
 	socketClient := socketmode.New(
 		slackClient.client,
 		socketmode.OptionDebug(false),
 		socketmode.OptionLog(log.New(os.Stdout, "socketmode: ", log.Lshortfile|log.LstdFlags)),
 	)
 
-	go func(ctx context.Context, client *slack.Client, socketClient *socketmode.Client, process ProcessFunction) {
+	stopped := make(chan struct{})
+	go func(ctx context.Context, socketClient *socketmode.Client, handlers SlackHandlers) {
+		defer close(stopped)
 		for {
 			select {
 			case <-ctx.Done():
 				return
 			case event := <-socketClient.Events:
-				if event.Type != socketmode.EventTypeEventsAPI {
-					continue
-				}
-				socketClient.Ack(*event.Request)
-				eventsAPIEvent, ok := event.Data.(slackevents.EventsAPIEvent)
-				if !ok {
-					log.Printf("Could not type cast the event to the EventsAPIEvent: %v\n", event)
-					continue
-				}
-
-				err := process(eventsAPIEvent)
-				if err != nil {
-					log.Printf("error to process event: %v\n", err)
-				}
+				handling.Add(1)
+				handleSocketEvent(ctx, socketClient, event, handlers)
+				handling.Done()
 			}
 		}
-	}(ctx, slackClient.client, socketClient, processFunction)
+	}(ctx, socketClient, handlers)
 
-	if err := socketClient.Run(); err != nil {
+	err := socketClient.RunContext(ctx)
+	<-stopped
+	if err != nil && ctx.Err() == nil {
 		return err
 	}
 
 	return nil
+}
+
+func handleSocketEvent(ctx context.Context, socketClient *socketmode.Client, event socketmode.Event, handlers SlackHandlers) {
+	switch event.Type {
+	case socketmode.EventTypeEventsAPI:
+		socketClient.Ack(*event.Request)
+		eventsAPIEvent, ok := event.Data.(slackevents.EventsAPIEvent)
+		if !ok {
+			log.Printf("Could not type cast the event to the EventsAPIEvent: %v\n", event)
+			return
+		}
+
+		handlers.event(ctx, eventsAPIEvent)
+	case socketmode.EventTypeInteractive:
+		callback, ok := event.Data.(slack.InteractionCallback)
+		if !ok {
+			socketClient.Ack(*event.Request)
+			log.Printf("Could not type cast the event to the InteractionCallback: %v\n", event)
+			return
+		}
+
+		if handlers.isSubmission(callback) {
+			socketClient.Ack(*event.Request, handlers.submission(ctx, callback, event.Request.Payload))
+			return
+		}
+
+		socketClient.Ack(*event.Request)
+		handlers.interaction(ctx, callback)
+	case socketmode.EventTypeSlashCommand:
+		command, ok := event.Data.(slack.SlashCommand)
+		if !ok {
+			socketClient.Ack(*event.Request)
+			log.Printf("Could not type cast the event to the SlashCommand: %v\n", event)
+			return
+		}
+
+		socketClient.Ack(*event.Request, handlers.command(ctx, command))
+	}
+}
+
+func (slackClient *SlackClientImpl) DownloadFile(ctx context.Context, file model.SlackFile, maxSize int64) (string, error) {
+	headers := map[string]string{
+		"Authorization": "Bearer " + slackClient.authToken,
+	}
+	return requestutil.DownloadFile(ctx, file.Url, headers, file.FileType, int64(file.Size), maxSize)
+}
+
+// GetUserName returns the name shown in Slack for the given user, preferring
+// the display name over the real name and the handle.
+func (slackClient *SlackClientImpl) GetUserName(ctx context.Context, userID string) (string, error) {
+	user, err := slackClient.client.GetUserInfoContext(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	for _, name := range []string{user.Profile.DisplayName, user.RealName, user.Name} {
+		if name != "" {
+			return name, nil
+		}
+	}
+
+	return userID, nil
+}
+
+// GetBotUserID returns the user ID of the bot, used to ignore its own messages.
+func (slackClient *SlackClientImpl) GetBotUserID(ctx context.Context) (string, error) {
+	response, err := slackClient.client.AuthTestContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	return response.UserID, nil
+}
+
+func (slackClient *SlackClientImpl) AddReaction(ctx context.Context, channelID, timestamp, reaction string) error {
+	err := slackClient.client.AddReactionContext(ctx, reaction, slack.NewRefToMessage(channelID, timestamp))
+	if err != nil && err.Error() == "already_reacted" {
+		return nil
+	}
+	return err
+}
+
+func (slackClient *SlackClientImpl) RemoveReaction(ctx context.Context, channelID, timestamp, reaction string) error {
+	err := slackClient.client.RemoveReactionContext(ctx, reaction, slack.NewRefToMessage(channelID, timestamp))
+	if err != nil && err.Error() == "no_reaction" {
+		return nil
+	}
+	return err
+}
+
+// PostText posts a plain text message and returns its timestamp, which is the
+// ID used to update it.
+func (slackClient *SlackClientImpl) PostText(ctx context.Context, channelID, threadTS, text string) (string, error) {
+	options := []slack.MsgOption{slack.MsgOptionText(text, false)}
+	if threadTS != "" {
+		options = append(options, slack.MsgOptionTS(threadTS))
+	}
+
+	_, timestamp, err := slackClient.client.PostMessageContext(ctx, channelID, options...)
+	return timestamp, err
+}
+
+func (slackClient *SlackClientImpl) UpdateText(ctx context.Context, channelID, timestamp, text string) error {
+	_, _, _, err := slackClient.client.UpdateMessageContext(ctx, channelID, timestamp, slack.MsgOptionText(text, false))
+	return err
+}
 
+// PublishRichMessage posts the message as Block Kit blocks inside a colored
+// attachment. Slack rejects the whole message when it can't fetch an image,
+// so it is posted again without the previews in that case.
+func (slackClient *SlackClientImpl) PublishRichMessage(ctx context.Context, message model.SlackRichMessage) error {
+	err := slackClient.postBlocks(ctx, message)
+	if err != nil && len(message.Images) > 0 && strings.Contains(err.Error(), "invalid_blocks") {
+		log.Printf("error to post the message with previews, posting it without them: %v\n", err)
+		message.Images = nil
+		err = slackClient.postBlocks(ctx, message)
+	}
+	return err
 }
 
-func (slackClient *SlackClientImpl) PublishMessage(pretext, text string, color model.SlackMessageColor) error {
+func (slackClient *SlackClientImpl) postBlocks(ctx context.Context, message model.SlackRichMessage) error {
+	channelID := message.ChannelID
+	if channelID == "" {
+		channelID = slackClient.channelID
+	}
+
 	attachment := slack.Attachment{
-		Pretext: pretext,
-		Text:    text,
-		Color:   string(color),
-		Fields: []slack.AttachmentField{
-			{
-				Title: "Date",
-				Value: time.Now().String(),
-			},
-		},
-	}
-
-	_, _, err := slackClient.client.PostMessage(
-		slackClient.channelID,
+		Color:    string(message.Color),
+		Fallback: message.Pretext,
+		Blocks:   slack.Blocks{BlockSet: richMessageBlocks(message)},
+	}
+
+	options := []slack.MsgOption{
+		slack.MsgOptionText(message.Pretext, false),
 		slack.MsgOptionAttachments(attachment),
-	)
-	if err != nil {
-		return err
+	}
+	if message.ThreadTS != "" {
+		options = append(options, slack.MsgOptionTS(message.ThreadTS))
 	}
 
-	return nil
+	_, _, err := slackClient.client.PostMessageContext(ctx, channelID, options...)
+	return err
 }
 
-func (slackClient *SlackClientImpl) DownloadFile(file model.SlackFile) (string, error) {
-	headers := map[string]string{
-		"Authorization": "Bearer " + slackClient.authToken,
+func richMessageBlocks(message model.SlackRichMessage) []slack.Block {
+	text := fmt.Sprintf("*%s*\n%s", message.Pretext, message.Text)
+	blocks := []slack.Block{
+		slack.NewSectionBlock(markdown(truncate(text, maxSectionText)), nil, nil),
+	}
+
+	if len(message.Buttons) > 0 {
+		elements := make([]slack.BlockElement, 0, maxButtons)
+		for index, button := range message.Buttons {
+			if index == maxButtons {
+				break
+			}
+			element := slack.NewButtonBlockElement(fmt.Sprintf("open_link_%d", index), button.Url,
+				slack.NewTextBlockObject(slack.PlainTextType, truncate(button.Text, 75), true, false))
+			element.URL = button.Url
+			elements = append(elements, element)
+		}
+		blocks = append(blocks, slack.NewActionBlock("", elements...))
+	}
+
+	if len(message.Files) > 0 {
+		blocks = append(blocks, slack.NewSectionBlock(markdown(truncate(filesTable(message.Files), maxSectionText)), nil, nil))
+	}
+
+	for _, image := range message.Images {
+		title := slack.NewTextBlockObject(slack.PlainTextType, truncate(image.Title, 200), false, false)
+		blocks = append(blocks, slack.NewImageBlock(image.Url, image.Title, "", title))
+	}
+
+	if len(message.Context) > 0 {
+		elements := make([]slack.MixedElement, 0, len(message.Context))
+		for _, context := range message.Context {
+			elements = append(elements, markdown(context))
+		}
+		blocks = append(blocks, slack.NewContextBlock("", elements...))
+	}
+
+	return blocks
+}
+
+// filesTable renders the files as an aligned code block, since Slack has no
+// table block.
+func filesTable(files []model.SlackFileLine) string {
+	width := 0
+	for index, file := range files {
+		if index < maxFileLines && len(file.Path) > width {
+			width = len(file.Path)
+		}
+	}
+
+	lines := make([]string, 0, maxFileLines+1)
+	for index, file := range files {
+		if index == maxFileLines {
+			lines = append(lines, fmt.Sprintf("... and %d more", len(files)-maxFileLines))
+			break
+		}
+		lines = append(lines, fmt.Sprintf("%-*s  %10s", width, file.Path, fileutil.HumanSize(file.Size)))
+	}
+
+	return "```" + strings.Join(lines, "\n") + "```"
+}
+
+func markdown(text string) *slack.TextBlockObject {
+	return slack.NewTextBlockObject(slack.MarkdownType, text, false, false)
+}
+
+func truncate(text string, length int) string {
+	runes := []rune(text)
+	if len(runes) <= length {
+		return text
+	}
+	return string(runes[:length-1]) + "…"
+}
+
+func (slackClient *SlackClientImpl) PostEphemeral(ctx context.Context, channelID, threadTS, userID, text string) error {
+	options := []slack.MsgOption{slack.MsgOptionText(text, false)}
+	if threadTS != "" {
+		options = append(options, slack.MsgOptionTS(threadTS))
+	}
+
+	_, err := slackClient.client.PostEphemeralContext(ctx, channelID, userID, options...)
+	return err
+}
+
+// PostApproval posts the summary of an upload with the buttons to approve or
+// cancel it. Both buttons carry the job ID as their value.
+func (slackClient *SlackClientImpl) PostApproval(ctx context.Context, approval model.SlackApproval) (string, error) {
+	text := fmt.Sprintf("%s Review the upload before the pull request is opened. It expires at <!date^%d^{time}|%s>.",
+		approval.Mention, approval.ExpiresAt.Unix(), approval.ExpiresAt.Format(time.Kitchen))
+
+	blocks := []slack.Block{
+		slack.NewSectionBlock(markdown(text), nil, nil),
+	}
+
+	if len(approval.Files) > 0 {
+		blocks = append(blocks, slack.NewSectionBlock(markdown(truncate(filesTable(approval.Files), maxSectionText)), nil, nil))
+	}
+
+	if len(approval.Warnings) > 0 {
+		warnings := ":warning: " + strings.Join(approval.Warnings, "\n:warning: ")
+		blocks = append(blocks, slack.NewSectionBlock(markdown(truncate(warnings, maxSectionText)), nil, nil))
+	}
+
+	approve := slack.NewButtonBlockElement(model.ApproveActionID, approval.JobID,
+		slack.NewTextBlockObject(slack.PlainTextType, "Approve", false, false))
+	approve.Style = slack.StylePrimary
+
+	cancel := slack.NewButtonBlockElement(model.CancelActionID, approval.JobID,
+		slack.NewTextBlockObject(slack.PlainTextType, "Cancel", false, false))
+	cancel.Style = slack.StyleDanger
+
+	blocks = append(blocks, slack.NewActionBlock("approval", approve, cancel))
+
+	options := []slack.MsgOption{
+		slack.MsgOptionText("Review the upload before the pull request is opened", false),
+		slack.MsgOptionBlocks(blocks...),
+	}
+	if approval.ThreadTS != "" {
+		options = append(options, slack.MsgOptionTS(approval.ThreadTS))
 	}
-	return requestutil.DownloadFile(file.Url, headers, file.FileType)
+
+	_, timestamp, err := slackClient.client.PostMessageContext(ctx, approval.ChannelID, options...)
+	return timestamp, err
+}
+
+// CloseApproval replaces the approval message with its outcome, removing the
+// buttons.
+func (slackClient *SlackClientImpl) CloseApproval(ctx context.Context, channelID, timestamp, text string) error {
+	_, _, _, err := slackClient.client.UpdateMessageContext(ctx, channelID, timestamp,
+		slack.MsgOptionText(text, false),
+		slack.MsgOptionBlocks(slack.NewSectionBlock(markdown(text), nil, nil)),
+	)
+	return err
 }
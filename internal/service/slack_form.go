@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/slack-go/slack"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/model"
+)
+
+// SubmissionFunction handles a submitted upload form and returns the
+// validation errors keyed by block ID, which are shown next to the fields.
+type SubmissionFunction func(context.Context, model.SlackUploadSubmission) (map[string]string, error)
+
+// fileInputElement is the file input of the modals, which this version of the
+// Slack client doesn't have.
+type fileInputElement struct {
+	Type      slack.MessageElementType `json:"type"`
+	ActionID  string                   `json:"action_id"`
+	FileTypes []string                 `json:"filetypes,omitempty"`
+	MaxFiles  int                      `json:"max_files,omitempty"`
+}
+
+func (element fileInputElement) ElementType() slack.MessageElementType {
+	return element.Type
+}
+
+// submittedFiles is the part of a view submission with the files of the file
+// inputs, which the Slack client drops when it parses the payload.
+type submittedFiles struct {
+	View struct {
+		State struct {
+			Values map[string]map[string]struct {
+				Files []model.SlackFile `json:"files"`
+			} `json:"values"`
+		} `json:"state"`
+	} `json:"view"`
+}
+
+// OpenUploadForm opens the modal used to upload assets without typing the
+// directives in a message.
+func (slackClient *SlackClientImpl) OpenUploadForm(ctx context.Context, triggerID string, form model.SlackUploadForm) error {
+	routes := make([]*slack.OptionBlockObject, 0, len(form.Routes))
+	for _, route := range form.Routes {
+		routes = append(routes, option(route))
+	}
+
+	blocks := []slack.Block{
+		slack.NewInputBlock(model.FormRouteBlockID, plainText("Route"),
+			slack.NewOptionsSelectBlockElement(slack.OptTypeStatic, plainText("Pick the repository"), model.FormRouteBlockID, routes...)),
+	}
+
+	if len(form.Categories) > 0 {
+		categories := make([]*slack.OptionBlockObject, 0, len(form.Categories))
+		for _, category := range form.Categories {
+			categories = append(categories, option(category))
+		}
+
+		category := slack.NewInputBlock(model.FormCategoryBlockID, plainText("Category"),
+			slack.NewOptionsSelectBlockElement(slack.OptTypeStatic, plainText("Pick a folder"), model.FormCategoryBlockID, categories...))
+		category.Optional = true
+		blocks = append(blocks, category)
+	}
+
+	title := slack.NewInputBlock(model.FormTitleBlockID, plainText("Pull request title"),
+		slack.NewPlainTextInputBlockElement(nil, model.FormTitleBlockID))
+
+	reviewers := slack.NewInputBlock(model.FormReviewersBlockID, plainText("Reviewers"),
+		slack.NewPlainTextInputBlockElement(plainText("GitHub users or org/team, separated by commas"), model.FormReviewersBlockID))
+	reviewers.Optional = true
+
+	descriptionElement := slack.NewPlainTextInputBlockElement(nil, model.FormDescriptionBlockID)
+	descriptionElement.Multiline = true
+	description := slack.NewInputBlock(model.FormDescriptionBlockID, plainText("Description"), descriptionElement)
+	description.Optional = true
+
+	files := slack.NewInputBlock(model.FormFilesBlockID, plainText("Zip file"), fileInputElement{
+		Type:      "file_input",
+		ActionID:  model.FormFilesBlockID,
+		FileTypes: []string{"zip"},
+		MaxFiles:  1,
+	})
+
+	blocks = append(blocks, title, reviewers, description, files)
+
+	_, err := slackClient.client.OpenViewContext(ctx, triggerID, slack.ModalViewRequest{
+		Type:       slack.VTModal,
+		CallbackID: model.UploadFormCallbackID,
+		Title:      plainText("Upload assets"),
+		Submit:     plainText("Upload"),
+		Close:      plainText("Cancel"),
+		Blocks:     slack.Blocks{BlockSet: blocks},
+	})
+	return err
+}
+
+// submitUploadForm returns the payload of the acknowledgement of the
+// submission, which closes the modal when it is nil.
+func submitUploadForm(ctx context.Context, handler SubmissionFunction, callback slack.InteractionCallback, payload json.RawMessage) interface{} {
+	submission, err := uploadSubmission(callback, payload)
+	if err != nil {
+		return slack.NewErrorsViewSubmissionResponse(map[string]string{model.FormFilesBlockID: err.Error()})
+	}
+
+	errors, err := handler(ctx, submission)
+	if err != nil {
+		errors = map[string]string{model.FormRouteBlockID: err.Error()}
+	}
+	if len(errors) > 0 {
+		return slack.NewErrorsViewSubmissionResponse(errors)
+	}
+
+	return nil
+}
+
+func uploadSubmission(callback slack.InteractionCallback, payload json.RawMessage) (model.SlackUploadSubmission, error) {
+	values := callback.View.State.Values
+	submission := model.SlackUploadSubmission{
+		UserID:      callback.User.ID,
+		Route:       values[model.FormRouteBlockID][model.FormRouteBlockID].SelectedOption.Value,
+		Category:    values[model.FormCategoryBlockID][model.FormCategoryBlockID].SelectedOption.Value,
+		Title:       values[model.FormTitleBlockID][model.FormTitleBlockID].Value,
+		Reviewers:   values[model.FormReviewersBlockID][model.FormReviewersBlockID].Value,
+		Description: values[model.FormDescriptionBlockID][model.FormDescriptionBlockID].Value,
+	}
+
+	var files submittedFiles
+	if err := json.Unmarshal(payload, &files); err != nil {
+		return submission, err
+	}
+	submission.Files = files.View.State.Values[model.FormFilesBlockID][model.FormFilesBlockID].Files
+
+	return submission, nil
+}
+
+func option(value string) *slack.OptionBlockObject {
+	return slack.NewOptionBlockObject(value, plainText(value), nil)
+}
+
+func plainText(text string) *slack.TextBlockObject {
+	return slack.NewTextBlockObject(slack.PlainTextType, text, false, false)
+}
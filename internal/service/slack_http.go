@@ -0,0 +1,211 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"io/ioutil"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type SlackHTTP interface {
+	Handler(ctx context.Context, handlers SlackHandlers) http.Handler
+}
+
+var StaleRequestError = fmt.Errorf("the request timestamp is too far from now")
+
+const (
+	slackSignatureHeader = "X-Slack-Signature"
+	slackTimestampHeader = "X-Slack-Request-Timestamp"
+	maxSlackRequestSize  = 1 << 20
+)
+
+// The paths of the Request URLs to configure in the Slack app.
+const (
+	SlackEventsPath       = "/slack/events"
+	SlackInteractionsPath = "/slack/interactions"
+	SlackCommandsPath     = "/slack/commands"
+)
+
+// SlackHTTPImpl receives the events, the interactions and the slash commands
+// over HTTP, as an alternative to socket mode. The events and interactions
+// still being processed are counted in handling, so the bot can wait for them
+// on its way down.
+type SlackHTTPImpl struct {
+	signingSecret []byte
+	maxSkew       time.Duration
+	handling      *sync.WaitGroup
+}
+
+func NewSlackHTTP(signingSecret string, maxSkew time.Duration, handling *sync.WaitGroup) SlackHTTP {
+	return &SlackHTTPImpl{
+		signingSecret: []byte(signingSecret),
+		maxSkew:       maxSkew,
+		handling:      handling,
+	}
+}
+
+// Handler verifies the signature of the requests and serves the three
+// Request URLs. Slack waits only three seconds for an answer, so the events
+// and the interactions are answered first and processed after, with the
+// given context since the one of the request ends with the answer.
+func (slackHTTP *SlackHTTPImpl) Handler(ctx context.Context, handlers SlackHandlers) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(SlackEventsPath, slackHTTP.verified(func(writer http.ResponseWriter, request *http.Request, payload []byte) {
+		slackHTTP.events(ctx, writer, payload, handlers)
+	}))
+	mux.Handle(SlackInteractionsPath, slackHTTP.verified(func(writer http.ResponseWriter, request *http.Request, payload []byte) {
+		slackHTTP.interactions(ctx, writer, request, handlers)
+	}))
+	mux.Handle(SlackCommandsPath, slackHTTP.verified(func(writer http.ResponseWriter, request *http.Request, payload []byte) {
+		slackHTTP.commands(writer, request, handlers)
+	}))
+	return mux
+}
+
+type verifiedHandler func(writer http.ResponseWriter, request *http.Request, payload []byte)
+
+// verified reads the body, checks its signature and makes it readable again
+// for the form parsing of the handler.
+func (slackHTTP *SlackHTTPImpl) verified(handler verifiedHandler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method != http.MethodPost {
+			writer.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		payload, err := ioutil.ReadAll(http.MaxBytesReader(writer, request.Body, maxSlackRequestSize))
+		if err != nil {
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		err = slackHTTP.verifySignature(request.Header.Get(slackSignatureHeader), request.Header.Get(slackTimestampHeader), payload)
+		if err != nil {
+			log.Printf("error to verify the Slack request: %v\n", err)
+			writer.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		request.Body = ioutil.NopCloser(bytes.NewReader(payload))
+		handler(writer, request, payload)
+	})
+}
+
+// verifySignature checks the v0 signature of Slack, which signs the
+// timestamp with the body. Old timestamps are refused to stop replays.
+func (slackHTTP *SlackHTTPImpl) verifySignature(signature, timestamp string, payload []byte) error {
+	if !strings.HasPrefix(signature, "v0=") || timestamp == "" {
+		return MissingSignatureError
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return InvalidSignatureError
+	}
+	if math.Abs(time.Since(time.Unix(seconds, 0)).Seconds()) > slackHTTP.maxSkew.Seconds() {
+		return StaleRequestError
+	}
+
+	expected, err := hex.DecodeString(strings.TrimPrefix(signature, "v0="))
+	if err != nil {
+		return InvalidSignatureError
+	}
+
+	mac := hmac.New(sha256.New, slackHTTP.signingSecret)
+	mac.Write([]byte("v0:" + timestamp + ":"))
+	mac.Write(payload)
+	if !hmac.Equal(mac.Sum(nil), expected) {
+		return InvalidSignatureError
+	}
+
+	return nil
+}
+
+func (slackHTTP *SlackHTTPImpl) events(ctx context.Context, writer http.ResponseWriter, payload []byte, handlers SlackHandlers) {
+	event, err := slackevents.ParseEvent(payload, slackevents.OptionNoVerifyToken())
+	if err != nil {
+		log.Printf("error to parse the Slack event: %v\n", err)
+		writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	switch event.Type {
+	case slackevents.URLVerification:
+		challenge, ok := event.Data.(*slackevents.EventsAPIURLVerificationEvent)
+		if !ok {
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		writer.Header().Set("Content-Type", "text/plain")
+		_, _ = writer.Write([]byte(challenge.Challenge))
+	case slackevents.CallbackEvent:
+		writer.WriteHeader(http.StatusOK)
+		slackHTTP.handling.Add(1)
+		go func() {
+			defer slackHTTP.handling.Done()
+			handlers.event(ctx, event)
+		}()
+	default:
+		writer.WriteHeader(http.StatusOK)
+	}
+}
+
+func (slackHTTP *SlackHTTPImpl) interactions(ctx context.Context, writer http.ResponseWriter, request *http.Request, handlers SlackHandlers) {
+	payload := json.RawMessage(request.FormValue("payload"))
+
+	var callback slack.InteractionCallback
+	if err := json.Unmarshal(payload, &callback); err != nil {
+		log.Printf("error to parse the Slack interaction: %v\n", err)
+		writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if handlers.isSubmission(callback) {
+		writeJSON(writer, handlers.submission(ctx, callback, payload))
+		return
+	}
+
+	writer.WriteHeader(http.StatusOK)
+	slackHTTP.handling.Add(1)
+	go func() {
+		defer slackHTTP.handling.Done()
+		handlers.interaction(ctx, callback)
+	}()
+}
+
+func (slackHTTP *SlackHTTPImpl) commands(writer http.ResponseWriter, request *http.Request, handlers SlackHandlers) {
+	command, err := slack.SlashCommandParse(request)
+	if err != nil {
+		log.Printf("error to parse the Slack command: %v\n", err)
+		writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(writer, handlers.command(request.Context(), command))
+}
+
+// writeJSON answers with the payload, or with an empty body when it is nil.
+func writeJSON(writer http.ResponseWriter, payload interface{}) {
+	if payload == nil {
+		writer.WriteHeader(http.StatusOK)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(writer).Encode(payload); err != nil {
+		log.Printf("error to write the Slack response: %v\n", err)
+	}
+}
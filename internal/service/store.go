@@ -0,0 +1,125 @@
+package service
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+type KeyValueStore interface {
+	Put(bucket, key string, value interface{}) error
+	Get(bucket, key string, value interface{}) (bool, error)
+	Delete(bucket, key string) error
+	Keys(bucket string) ([]string, error)
+	Close() error
+}
+
+// JSONStore is a KeyValueStore kept in memory and persisted to a single JSON
+// file on every write.
+type JSONStore struct {
+	path    string
+	mutex   sync.Mutex
+	buckets map[string]map[string]json.RawMessage
+}
+
+func NewJSONStore(path string) (KeyValueStore, error) {
+	store := &JSONStore{
+		path:    path,
+		buckets: map[string]map[string]json.RawMessage{},
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(content) > 0 {
+		if err = json.Unmarshal(content, &store.buckets); err != nil {
+			return nil, err
+		}
+	}
+
+	return store, nil
+}
+
+func (store *JSONStore) Put(bucket, key string, value interface{}) error {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.buckets[bucket] == nil {
+		store.buckets[bucket] = map[string]json.RawMessage{}
+	}
+	store.buckets[bucket][key] = content
+
+	return store.flush()
+}
+
+func (store *JSONStore) Get(bucket, key string, value interface{}) (bool, error) {
+	store.mutex.Lock()
+	content, ok := store.buckets[bucket][key]
+	store.mutex.Unlock()
+
+	if !ok {
+		return false, nil
+	}
+
+	return true, json.Unmarshal(content, value)
+}
+
+func (store *JSONStore) Delete(bucket, key string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if _, ok := store.buckets[bucket][key]; !ok {
+		return nil
+	}
+	delete(store.buckets[bucket], key)
+
+	return store.flush()
+}
+
+func (store *JSONStore) Keys(bucket string) ([]string, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	keys := make([]string, 0, len(store.buckets[bucket]))
+	for key := range store.buckets[bucket] {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// Close has nothing to do, every write is already on disk.
+func (store *JSONStore) Close() error {
+	return nil
+}
+
+// flush writes the whole store to a temporary file and renames it over the
+// previous one, so a crash never leaves a truncated store behind.
+func (store *JSONStore) flush() error {
+	content, err := json.Marshal(store.buckets)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(store.path), 0700); err != nil {
+		return err
+	}
+
+	tempPath := store.path + ".tmp"
+	if err = ioutil.WriteFile(tempPath, content, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tempPath, store.path)
+}
@@ -0,0 +1,281 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/model"
+	"github.com/wallacehenriquesilva/slack-assets-bot/internal/util/requestutil"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+type TeamsClient interface {
+	Handler(ctx context.Context, handler TeamsActivityFunction) http.Handler
+	SendActivity(ctx context.Context, serviceUrl, conversationID string, activity model.TeamsActivity) (string, error)
+	UpdateActivity(ctx context.Context, serviceUrl, conversationID, activityID string, activity model.TeamsActivity) error
+	DownloadFile(ctx context.Context, url, extension string, size, maxSize int64) (string, error)
+}
+
+type TeamsActivityFunction func(context.Context, model.TeamsActivity) error
+
+var (
+	TeamsRequestError        = fmt.Errorf("the Teams request failed")
+	UnknownConversationError = fmt.Errorf("the bot didn't receive any activity from the conversation")
+)
+
+const (
+	TeamsMessagesPath       = "/teams/messages"
+	DefaultTeamsTenantID    = "botframework.com"
+	teamsTokenURL           = "https://login.microsoftonline.com/%s/oauth2/v2.0/token"
+	teamsTokenScope         = "https://api.botframework.com/.default"
+	maxTeamsActivitySize    = 1 << 20
+	teamsTokenRenewalMargin = time.Minute
+)
+
+type teamsToken struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+type TeamsClientImpl struct {
+	appID       string
+	appPassword string
+	tenantID    string
+	verifier    *botFrameworkVerifier
+	client      *http.Client
+	mutex       sync.Mutex
+	serviceUrls map[string]string
+	token       string
+	expiresAt   time.Time
+	handling    *sync.WaitGroup
+}
+
+// NewTeamsClient creates a client for the bot registered with the app ID and
+// password. The tenant is the one of a single tenant bot, or empty for a
+// multi tenant one. The activities still being processed are counted in
+// handling.
+func NewTeamsClient(appID, appPassword, tenantID string, handling *sync.WaitGroup) TeamsClient {
+	if tenantID == "" {
+		tenantID = DefaultTeamsTenantID
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	return &TeamsClientImpl{
+		appID:       appID,
+		appPassword: appPassword,
+		tenantID:    tenantID,
+		verifier:    newBotFrameworkVerifier(appID, DefaultBotFrameworkOpenIDURL, client),
+		client:      client,
+		serviceUrls: map[string]string{},
+		handling:    handling,
+	}
+}
+
+// Handler is the messaging endpoint of the bot. It verifies the token of the
+// activity, answers right away and leaves the activity to the handler, with
+// the given context since the one of the request ends with the answer. The
+// service URL of the conversation is kept to answer it.
+func (teamsClient *TeamsClientImpl) Handler(ctx context.Context, handler TeamsActivityFunction) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method != http.MethodPost {
+			writer.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		var activity model.TeamsActivity
+		err := json.NewDecoder(io.LimitReader(request.Body, maxTeamsActivitySize)).Decode(&activity)
+		if err != nil || activity.Conversation == nil {
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if err = teamsClient.verifier.verify(request.Context(), request.Header.Get("Authorization"), activity.ServiceUrl); err != nil {
+			log.Printf("error to verify the Teams activity: %v\n", err)
+			writer.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		teamsClient.rememberServiceURL(activity.Conversation.ID, activity.ServiceUrl)
+
+		writer.WriteHeader(http.StatusOK)
+
+		teamsClient.handling.Add(1)
+		go func() {
+			defer teamsClient.handling.Done()
+			if err := handler(ctx, activity); err != nil {
+				log.Printf("error to process the Teams activity: %v\n", err)
+			}
+		}()
+	})
+}
+
+// SendActivity sends the activity to the conversation, as a reply when it has
+// a ReplyToID, and returns its ID. The service URL is the one the activities
+// of the conversation came with, the last one received is used when empty.
+func (teamsClient *TeamsClientImpl) SendActivity(ctx context.Context, serviceUrl, conversationID string, activity model.TeamsActivity) (string, error) {
+	path := "/v3/conversations/" + url.PathEscape(conversationID) + "/activities"
+	if activity.ReplyToID != "" {
+		path += "/" + url.PathEscape(activity.ReplyToID)
+	}
+
+	var created struct {
+		ID string `json:"id"`
+	}
+	err := teamsClient.request(ctx, http.MethodPost, serviceUrl, conversationID, path, activity, &created)
+	return created.ID, err
+}
+
+func (teamsClient *TeamsClientImpl) UpdateActivity(ctx context.Context, serviceUrl, conversationID, activityID string, activity model.TeamsActivity) error {
+	activity.ID = activityID
+	path := "/v3/conversations/" + url.PathEscape(conversationID) + "/activities/" + url.PathEscape(activityID)
+	return teamsClient.request(ctx, http.MethodPut, serviceUrl, conversationID, path, activity, nil)
+}
+
+// DownloadFile downloads the file of an attachment. The bot token is only
+// sent to the Bot Framework services, the download URLs of the files sent to
+// the bot are already authorized.
+func (teamsClient *TeamsClientImpl) DownloadFile(ctx context.Context, fileURL, extension string, size, maxSize int64) (string, error) {
+	headers := map[string]string{}
+	if teamsClient.isServiceURL(fileURL) {
+		token, err := teamsClient.getToken(ctx)
+		if err != nil {
+			return "", err
+		}
+		headers["Authorization"] = "Bearer " + token
+	}
+	return requestutil.DownloadFile(ctx, fileURL, headers, extension, size, maxSize)
+}
+
+func (teamsClient *TeamsClientImpl) isServiceURL(fileURL string) bool {
+	teamsClient.mutex.Lock()
+	defer teamsClient.mutex.Unlock()
+
+	for _, serviceUrl := range teamsClient.serviceUrls {
+		if strings.HasPrefix(fileURL, serviceUrl+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// rememberServiceURL keeps the service URL of the conversation, which also
+// tells the files the bot token can be sent with.
+func (teamsClient *TeamsClientImpl) rememberServiceURL(conversationID, serviceUrl string) {
+	teamsClient.mutex.Lock()
+	defer teamsClient.mutex.Unlock()
+	teamsClient.serviceUrls[conversationID] = strings.TrimSuffix(serviceUrl, "/")
+}
+
+// serviceURL returns the given service URL, which was saved with a job that
+// may have outlived the bot, or the last one the conversation came with.
+func (teamsClient *TeamsClientImpl) serviceURL(serviceUrl, conversationID string) (string, error) {
+	if serviceUrl != "" {
+		teamsClient.rememberServiceURL(conversationID, serviceUrl)
+		return strings.TrimSuffix(serviceUrl, "/"), nil
+	}
+
+	teamsClient.mutex.Lock()
+	defer teamsClient.mutex.Unlock()
+
+	serviceUrl, ok := teamsClient.serviceUrls[conversationID]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", UnknownConversationError, conversationID)
+	}
+	return serviceUrl, nil
+}
+
+// getToken returns the token of the bot, requested again shortly before it
+// expires.
+func (teamsClient *TeamsClientImpl) getToken(ctx context.Context) (string, error) {
+	teamsClient.mutex.Lock()
+	defer teamsClient.mutex.Unlock()
+
+	if teamsClient.token != "" && time.Now().Before(teamsClient.expiresAt) {
+		return teamsClient.token, nil
+	}
+
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {teamsClient.appID},
+		"client_secret": {teamsClient.appPassword},
+		"scope":         {teamsTokenScope},
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf(teamsTokenURL, teamsClient.tenantID),
+		strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := teamsClient.client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%w: token request: %d", TeamsRequestError, response.StatusCode)
+	}
+
+	var token teamsToken
+	if err = json.NewDecoder(response.Body).Decode(&token); err != nil {
+		return "", err
+	}
+
+	teamsClient.token = token.AccessToken
+	teamsClient.expiresAt = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - teamsTokenRenewalMargin)
+	return token.AccessToken, nil
+}
+
+func (teamsClient *TeamsClientImpl) request(ctx context.Context, method, serviceUrl, conversationID, path string, body,
+	result interface{}) error {
+	serviceUrl, err := teamsClient.serviceURL(serviceUrl, conversationID)
+	if err != nil {
+		return err
+	}
+
+	token, err := teamsClient.getToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	content, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, serviceUrl+path, bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", "Bearer "+token)
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := teamsClient.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	content, err = ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+
+	if response.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("%w: %s %s: %d %s", TeamsRequestError, method, path, response.StatusCode, strings.TrimSpace(string(content)))
+	}
+
+	if result == nil || len(content) == 0 {
+		return nil
+	}
+	return json.Unmarshal(content, result)
+}
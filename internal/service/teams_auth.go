@@ -0,0 +1,234 @@
+package service
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var InvalidTokenError = fmt.Errorf("the Bot Framework token is invalid")
+
+const (
+	DefaultBotFrameworkOpenIDURL = "https://login.botframework.com/v1/.well-known/openidconfiguration"
+	botFrameworkIssuer           = "https://api.botframework.com"
+	botFrameworkKeysTTL          = 24 * time.Hour
+	botFrameworkKeysRefetch      = 5 * time.Minute
+	botFrameworkClockSkew        = 5 * time.Minute
+)
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type botFrameworkClaims struct {
+	Issuer     string      `json:"iss"`
+	Audience   interface{} `json:"aud"`
+	Expiry     int64       `json:"exp"`
+	NotBefore  int64       `json:"nbf"`
+	ServiceUrl string      `json:"serviceurl"`
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// botFrameworkVerifier checks the tokens the Bot Framework sends with the
+// activities, with the signing keys it publishes, fetched once a day or when
+// a token is signed with an unknown key. The keys are fetched at most once
+// every few minutes, the tokens with an unknown key are refused in between.
+type botFrameworkVerifier struct {
+	appID       string
+	openIDURL   string
+	client      *http.Client
+	mutex       sync.Mutex
+	keys        map[string]*rsa.PublicKey
+	fetchedAt   time.Time
+	attemptedAt time.Time
+}
+
+func newBotFrameworkVerifier(appID, openIDURL string, client *http.Client) *botFrameworkVerifier {
+	return &botFrameworkVerifier{
+		appID:     appID,
+		openIDURL: openIDURL,
+		client:    client,
+	}
+}
+
+// verify checks the signature, the issuer, the audience, the validity and the
+// service URL of the token.
+func (verifier *botFrameworkVerifier) verify(ctx context.Context, authorization, serviceUrl string) error {
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		return fmt.Errorf("%w: the token is missing", InvalidTokenError)
+	}
+
+	parts := strings.Split(strings.TrimPrefix(authorization, "Bearer "), ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("%w: malformed token", InvalidTokenError)
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return err
+	}
+	if header.Alg != "RS256" {
+		return fmt.Errorf("%w: unexpected algorithm %s", InvalidTokenError, header.Alg)
+	}
+
+	key, err := verifier.key(ctx, header.Kid)
+	if err != nil {
+		return err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return fmt.Errorf("%w: malformed signature", InvalidTokenError)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return fmt.Errorf("%w: bad signature", InvalidTokenError)
+	}
+
+	var claims botFrameworkClaims
+	if err = decodeSegment(parts[1], &claims); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	switch {
+	case claims.Issuer != botFrameworkIssuer:
+		return fmt.Errorf("%w: unexpected issuer %s", InvalidTokenError, claims.Issuer)
+	case !hasAudience(claims.Audience, verifier.appID):
+		return fmt.Errorf("%w: the token is not for this bot", InvalidTokenError)
+	case now.Add(-botFrameworkClockSkew).After(time.Unix(claims.Expiry, 0)):
+		return fmt.Errorf("%w: the token expired", InvalidTokenError)
+	case claims.NotBefore != 0 && now.Add(botFrameworkClockSkew).Before(time.Unix(claims.NotBefore, 0)):
+		return fmt.Errorf("%w: the token is not valid yet", InvalidTokenError)
+	case claims.ServiceUrl != "" && claims.ServiceUrl != serviceUrl:
+		return fmt.Errorf("%w: the service URL doesn't match", InvalidTokenError)
+	}
+
+	return nil
+}
+
+func (verifier *botFrameworkVerifier) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	verifier.mutex.Lock()
+	defer verifier.mutex.Unlock()
+
+	key, ok := verifier.keys[kid]
+	recentlyAttempted := time.Since(verifier.attemptedAt) < botFrameworkKeysRefetch
+	if ok && (recentlyAttempted || time.Since(verifier.fetchedAt) < botFrameworkKeysTTL) {
+		return key, nil
+	}
+	if recentlyAttempted {
+		return nil, fmt.Errorf("%w: unknown key %s", InvalidTokenError, kid)
+	}
+
+	verifier.attemptedAt = time.Now()
+	keys, err := verifier.fetchKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	verifier.keys = keys
+	verifier.fetchedAt = time.Now()
+
+	if key, ok = keys[kid]; !ok {
+		return nil, fmt.Errorf("%w: unknown key %s", InvalidTokenError, kid)
+	}
+	return key, nil
+}
+
+func (verifier *botFrameworkVerifier) fetchKeys(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	var configuration struct {
+		JwksURI string `json:"jwks_uri"`
+	}
+	if err := verifier.getJSON(ctx, verifier.openIDURL, &configuration); err != nil {
+		return nil, err
+	}
+
+	var keySet struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := verifier.getJSON(ctx, configuration.JwksURI, &keySet); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, webKey := range keySet.Keys {
+		if webKey.Kty != "RSA" {
+			continue
+		}
+
+		modulus, err := base64.RawURLEncoding.DecodeString(webKey.N)
+		if err != nil {
+			continue
+		}
+		exponent, err := base64.RawURLEncoding.DecodeString(webKey.E)
+		if err != nil {
+			continue
+		}
+
+		keys[webKey.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(modulus),
+			E: int(new(big.Int).SetBytes(exponent).Int64()),
+		}
+	}
+
+	return keys, nil
+}
+
+func (verifier *botFrameworkVerifier) getJSON(ctx context.Context, url string, value interface{}) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	response, err := verifier.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: GET %s: %d", TeamsRequestError, url, response.StatusCode)
+	}
+	return json.NewDecoder(response.Body).Decode(value)
+}
+
+func decodeSegment(segment string, value interface{}) error {
+	content, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return fmt.Errorf("%w: malformed segment", InvalidTokenError)
+	}
+	if err = json.Unmarshal(content, value); err != nil {
+		return fmt.Errorf("%w: malformed segment", InvalidTokenError)
+	}
+	return nil
+}
+
+// hasAudience accepts the audience as a single string or as a list.
+func hasAudience(audience interface{}, appID string) bool {
+	switch audience := audience.(type) {
+	case string:
+		return audience == appID
+	case []interface{}:
+		for _, item := range audience {
+			if item == appID {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -15,17 +15,32 @@ type File struct {
 	RemotePath string
 }
 
-func NewFile(extension string) (*os.File, error) {
-	fileName, err := generateFileName(extension)
+// tempDir is where the downloads are written, the system temp folder unless
+// the bot set a folder of its own.
+var tempDir = os.TempDir()
+
+// NewTempDir creates a folder of the process in the system temp folder and
+// writes the next downloads in it, so the whole folder can be removed when
+// the bot stops.
+func NewTempDir(prefix string) (string, error) {
+	dir, err := os.MkdirTemp("", prefix)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
-	csvFile, err := os.Create(fileName)
+	tempDir = dir
+	return dir, nil
+}
+
+// NewFile creates an empty file in the temp folder that only the bot can
+// read.
+func NewFile(extension string) (*os.File, error) {
+	fileName, err := generateFileName(extension)
 	if err != nil {
 		return nil, err
 	}
-	return csvFile, nil
+
+	return os.OpenFile(fileName, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
 }
 
 // generateFileName creates an uuid4 file name in the temp folder.
@@ -35,19 +50,30 @@ func generateFileName(extension string) (string, error) {
 		return "", err
 	}
 
-	tempDir := os.TempDir()
 	return fmt.Sprintf("%s/%s.%s", tempDir, u4, extension), nil
 }
 
-func UnzipFiles(path string, ignoreFileFunction func(string) bool) ([]File, error) {
-	var files []File
-
+// UnzipFiles extracts the archive in a folder of its own in the temp folder
+// and returns the folder with the files. The folder is removed when the
+// extraction fails.
+func UnzipFiles(path string, ignoreFileFunction func(string) bool) (string, []File, error) {
 	u4, err := uuid.NewV4()
 	if err != nil {
-		return nil, err
+		return "", nil, err
 	}
 
-	destination := u4.String()
+	destination := filepath.Join(tempDir, u4.String())
+	files, err := unzipFiles(path, destination, ignoreFileFunction)
+	if err != nil {
+		_ = os.RemoveAll(destination)
+		return "", nil, err
+	}
+	return destination, files, nil
+}
+
+func unzipFiles(path, destination string, ignoreFileFunction func(string) bool) ([]File, error) {
+	var files []File
+
 	archive, err := zip.OpenReader(path)
 	if err != nil {
 		return nil, err
@@ -107,3 +133,18 @@ func DeleteFiles(files ...string) error {
 	}
 	return nil
 }
+
+// HumanSize formats a size in bytes with a binary unit, like "1.5 MB".
+func HumanSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
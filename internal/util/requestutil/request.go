@@ -1,41 +1,83 @@
 package requestutil
 
 import (
+	"context"
+	"fmt"
 	"github.com/wallacehenriquesilva/slack-assets-bot/internal/util/fileutil"
-	"io/fs"
-	"io/ioutil"
+	"io"
+	"mime"
 	"net/http"
+	"os"
+	"strings"
+	"time"
 )
 
-func DownloadFile(url string, headers map[string]string, fileExtension string) (string, error) {
-	request, err := getWithHeaders(url, headers)
+var (
+	ContentTypeError  = fmt.Errorf("the download is not a file")
+	FileTooLargeError = fmt.Errorf("the file is too large")
+	SizeMismatchError = fmt.Errorf("the download doesn't match the size of the file")
+)
+
+// StatusError is a download answered with a status other than 200. The 5xx
+// and the 429 may succeed when tried again.
+type StatusError struct {
+	StatusCode int
+}
+
+func (statusError *StatusError) Error() string {
+	return fmt.Sprintf("the download failed with the status %d %s", statusError.StatusCode,
+		http.StatusText(statusError.StatusCode))
+}
+
+func (statusError *StatusError) Retryable() bool {
+	return statusError.StatusCode >= http.StatusInternalServerError ||
+		statusError.StatusCode == http.StatusTooManyRequests
+}
+
+// downloadClient stops a download that hangs even when the context has no
+// deadline.
+var downloadClient = &http.Client{Timeout: 10 * time.Minute}
+
+// DownloadFile streams the file to a temp file only the bot can read. size is
+// the size the chat announced for the file and maxSize the largest file
+// accepted, both are ignored when zero. A file announced, or turning out,
+// larger than maxSize is refused without reading the rest of it, and a
+// download of another size than the announced one is discarded.
+func DownloadFile(ctx context.Context, url string, headers map[string]string, fileExtension string,
+	size, maxSize int64) (string, error) {
+	if maxSize > 0 && size > maxSize {
+		return "", tooLarge(maxSize)
+	}
+
+	request, err := getWithHeaders(ctx, url, headers)
 	if err != nil {
 		return "", err
 	}
 
-	client := &http.Client{}
-	resp, err := client.Do(request)
+	resp, err := downloadClient.Do(request)
 	if err != nil {
 		return "", err
 	}
 
 	defer resp.Body.Close()
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
+	if resp.StatusCode != http.StatusOK {
+		return "", &StatusError{StatusCode: resp.StatusCode}
 	}
 
-	file, err := bodyToFile(body, fileExtension)
-	if err != nil {
+	if err = checkContentType(resp.Header.Get("Content-Type")); err != nil {
 		return "", err
 	}
 
-	return file, nil
+	if maxSize > 0 && resp.ContentLength > maxSize {
+		return "", tooLarge(maxSize)
+	}
+
+	return bodyToFile(resp.Body, fileExtension, size, maxSize)
 }
 
-func getWithHeaders(url string, headers map[string]string) (*http.Request, error) {
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+func getWithHeaders(ctx context.Context, url string, headers map[string]string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -47,16 +89,61 @@ func getWithHeaders(url string, headers map[string]string) (*http.Request, error
 	return req, err
 }
 
-func bodyToFile(body []byte, fileExtension string) (string, error) {
+// checkContentType refuses the pages and the API errors, which is what the
+// chats answer with when the download isn't authorized.
+func checkContentType(contentType string) error {
+	if contentType == "" {
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ContentTypeError, contentType)
+	}
+	if strings.HasPrefix(mediaType, "text/") || mediaType == "application/json" {
+		return fmt.Errorf("%w: %s", ContentTypeError, mediaType)
+	}
+	return nil
+}
+
+// bodyToFile copies the body to the file, reading one byte past maxSize to
+// tell a file of exactly maxSize from a larger one. The file is removed on
+// any error.
+func bodyToFile(body io.Reader, fileExtension string, size, maxSize int64) (path string, err error) {
 	file, err := fileutil.NewFile(fileExtension)
 	if err != nil {
 		return "", err
 	}
 
-	err = ioutil.WriteFile(file.Name(), body, fs.ModeTemporary)
+	defer func() {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			_ = os.Remove(file.Name())
+			path = ""
+		}
+	}()
+
+	if maxSize > 0 {
+		body = io.LimitReader(body, maxSize+1)
+	}
+
+	written, err := io.Copy(file, body)
 	if err != nil {
 		return "", err
 	}
 
+	if maxSize > 0 && written > maxSize {
+		return "", tooLarge(maxSize)
+	}
+	if size > 0 && written != size {
+		return "", fmt.Errorf("%w: got %d bytes instead of %d", SizeMismatchError, written, size)
+	}
+
 	return file.Name(), nil
 }
+
+func tooLarge(maxSize int64) error {
+	return fmt.Errorf("%w, the limit is %s", FileTooLargeError, fileutil.HumanSize(maxSize))
+}